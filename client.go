@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"ghhooks.com/hook/httpinterface"
+	"github.com/gorilla/websocket"
+)
+
+// ANSI colors of status markers, left out when stdout is not a terminal or NO_COLOR is set
+const (
+	COLOR_RED    = "\033[31m"
+	COLOR_GREEN  = "\033[32m"
+	COLOR_YELLOW = "\033[33m"
+	COLOR_RESET  = "\033[0m"
+)
+
+// client talks to a remote ghhooks server for trigger, status, logs and watch subcommands
+type client struct {
+	// server url along with basePath, without trailing slash
+	server string
+	token  string
+	http   *http.Client
+}
+
+// clientFlags - -server and -token flags of client subcommands, HOOK_SERVER and HOOK_TOKEN when not given
+func clientFlags(fs *flag.FlagSet) (*string, *string) {
+	server := os.Getenv("HOOK_SERVER")
+	if server == "" {
+		server = "http://localhost:4444"
+	}
+	serverURL := fs.String("server", server, "url of ghhooks server along with its basePath, HOOK_SERVER when not given")
+	token := fs.String("token", os.Getenv("HOOK_TOKEN"), "api token sent as bearer token, HOOK_TOKEN when not given")
+	return serverURL, token
+}
+
+func newClient(server string, token string) (*client, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server url %s, expected http(s)://host[:port][/basePath]", server)
+	}
+	return &client{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		http:   &http.Client{},
+	}, nil
+}
+
+// parseArgs - parses flags that come before and after positional arguments, returns the positional ones
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func (c *client) header() http.Header {
+	header := make(http.Header)
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	return header
+}
+
+// do - sends request to server, responses with status codes in expected are returned, others are errors
+func (c *client) do(method string, path string, query url.Values, expected ...int) (*http.Response, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.header()
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	for _, code := range expected {
+		if res.StatusCode == code {
+			return res, nil
+		}
+	}
+	defer res.Body.Close()
+	return nil, fmt.Errorf("%s %s: %s", method, path, responseError(res))
+}
+
+// responseError - error message of response, api v1 and the other endpoints report errors differently
+func responseError(res *http.Response) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if json.Unmarshal(b, &body) == nil && len(body.Error) > 0 {
+		var message string
+		if json.Unmarshal(body.Error, &message) == nil {
+			return message
+		}
+		var apiError httpinterface.APIErrorBody
+		if json.Unmarshal(body.Error, &apiError) == nil && apiError.Message != "" {
+			return apiError.Message
+		}
+	}
+	return res.Status
+}
+
+func (c *client) getJSON(path string, query url.Values, v any) error {
+	res, err := c.do("GET", path, query)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// liveState - build state as sent by status and live feeds, step errors only tell if a step failed
+type liveState struct {
+	ID          int64      `json:"id"`
+	BuildStatus string     `json:"buildStatus"`
+	Pipeline    string     `json:"pipeline"`
+	Steps       [][]string `json:"steps"`
+	StepResults []struct {
+		Error       json.RawMessage `json:"error"`
+		Output      string          `json:"output"`
+		Description string          `json:"description"`
+		Duration    float64         `json:"duration"`
+	} `json:"stepResults"`
+	Trigger core.Trigger `json:"trigger"`
+}
+
+func (s liveState) stepFailed(i int) bool {
+	e := s.StepResults[i].Error
+	return len(e) > 0 && string(e) != "null"
+}
+
+func colored() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// marker - colored marker of build or step status
+func marker(status string) string {
+	mark, color := "•", COLOR_YELLOW
+	switch status {
+	case core.SUCCESS:
+		mark, color = "✔", COLOR_GREEN
+	case core.FAILED:
+		mark, color = "✘", COLOR_RED
+	case "skipped":
+		mark, color = "-", ""
+	}
+	if !colored() || color == "" {
+		return mark
+	}
+	return color + mark + COLOR_RESET
+}
+
+// clientCommand - wraps client subcommands with -server and -token flags, usage lists positional arguments
+func clientCommand(name string, usage string, setup func(fs *flag.FlagSet) func(c *client, args []string) int) func(args []string) int {
+	return func(args []string) int {
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "usage: hook %s [flags] %s\n", name, usage)
+			fs.PrintDefaults()
+		}
+		server, token := clientFlags(fs)
+		run := setup(fs)
+		positional := parseArgs(fs, args)
+		c, err := newClient(*server, *token)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return run(c, positional)
+	}
+}
+
+// triggerCommand - hook trigger <project>, queues a build of project pipeline
+func triggerCommand(fs *flag.FlagSet) func(c *client, args []string) int {
+	pipeline := fs.String("pipeline", "", "pipeline to build, default pipeline of project when empty")
+	wait := fs.Bool("wait", false, "wait for the build to finish, exit code tells if it succeeded")
+	timeout := fs.Int("timeout", 0, "seconds to wait for with -wait, 10 minutes when 0")
+	return func(c *client, args []string) int {
+		if len(args) != 1 {
+			fs.Usage()
+			return 2
+		}
+		query := url.Values{}
+		if *pipeline != "" {
+			query.Set("pipeline", *pipeline)
+		}
+		if *wait {
+			query.Set("wait", "true")
+			if *timeout > 0 {
+				query.Set("timeout", strconv.Itoa(*timeout))
+			}
+		}
+		res, err := c.do("POST", "/"+url.PathEscape(args[0])+"/trigger", query, 422, 504)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer res.Body.Close()
+		var body struct {
+			Message  string      `json:"message"`
+			BuildIDs []int64     `json:"buildIds"`
+			Builds   []liveState `json:"builds"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, id := range body.BuildIDs {
+			fmt.Printf("build #%d of %s queued\n", id, args[0])
+		}
+		for _, build := range body.Builds {
+			fmt.Printf("%s build #%d %s\n", marker(build.BuildStatus), build.ID, build.BuildStatus)
+		}
+		if res.StatusCode != 201 && res.StatusCode != 200 {
+			fmt.Println(body.Message)
+			return 1
+		}
+		return 0
+	}
+}
+
+// statusCommand - hook status [project], last build of every pipeline of project or of all projects
+func statusCommand(fs *flag.FlagSet) func(c *client, args []string) int {
+	return func(c *client, args []string) int {
+		if len(args) > 1 {
+			fs.Usage()
+			return 2
+		}
+		projects := make([]httpinterface.APIProject, 0)
+		if len(args) == 1 {
+			var project httpinterface.APIProject
+			if err := c.getJSON(httpinterface.API_V1_PREFIX+"/projects/"+url.PathEscape(args[0]), nil, &project); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			projects = append(projects, project)
+		} else {
+			for page := 1; ; page++ {
+				items := make([]httpinterface.APIProject, 0)
+				res := httpinterface.APIPage{Items: &items}
+				query := url.Values{"page": {strconv.Itoa(page)}, "perPage": {strconv.Itoa(httpinterface.MAX_PER_PAGE)}}
+				if err := c.getJSON(httpinterface.API_V1_PREFIX+"/projects", query, &res); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 1
+				}
+				projects = append(projects, items...)
+				if len(items) == 0 || len(projects) >= res.Total {
+					break
+				}
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROJECT\tPIPELINE\tBUILD\tSTATUS\tSTARTED\tQUEUED")
+		for _, project := range projects {
+			for _, pipeline := range project.Pipelines {
+				build, status, started := "-", "-", "-"
+				if last := pipeline.LastBuild; last != nil {
+					build = "#" + strconv.FormatInt(last.ID, 10)
+					status = marker(last.Status) + " " + last.Status
+					if last.StartedAt != nil {
+						started = last.StartedAt.Local().Format(time.RFC3339)
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", project.Name, pipeline.Name, build, status, started, project.Queue.Depth)
+			}
+		}
+		w.Flush()
+		return 0
+	}
+}
+
+// logsCommand - hook logs <project> [build] [step], output of every step of the build or of one step,
+// latest build of project when build is not given
+func logsCommand(fs *flag.FlagSet) func(c *client, args []string) int {
+	return func(c *client, args []string) int {
+		if len(args) < 1 || len(args) > 3 {
+			fs.Usage()
+			return 2
+		}
+		project := args[0]
+		var id int64
+		if len(args) > 1 {
+			var err error
+			if id, err = strconv.ParseInt(strings.TrimPrefix(args[1], "#"), 10, 64); err != nil {
+				fmt.Fprintln(os.Stderr, "build should be a build id")
+				return 2
+			}
+		} else {
+			items := make([]httpinterface.APIBuild, 0)
+			res := httpinterface.APIPage{Items: &items}
+			if err := c.getJSON(httpinterface.API_V1_PREFIX+"/projects/"+url.PathEscape(project)+"/builds", url.Values{"perPage": {"1"}}, &res); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if len(items) == 0 {
+				fmt.Fprintf(os.Stderr, "project %s has no builds yet\n", project)
+				return 1
+			}
+			id = items[0].ID
+		}
+
+		var build httpinterface.APIBuild
+		if err := c.getJSON(httpinterface.API_V1_PREFIX+"/builds/"+strconv.FormatInt(id, 10), nil, &build); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if build.Project != project {
+			fmt.Fprintf(os.Stderr, "build #%d is not a build of %s\n", id, project)
+			return 1
+		}
+		steps := build.Steps
+		if len(args) > 2 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil || n < 0 || n >= len(build.Steps) {
+				fmt.Fprintf(os.Stderr, "build #%d has no step %s, steps are numbered from 0\n", id, args[2])
+				return 2
+			}
+			steps = build.Steps[n : n+1]
+		}
+
+		for _, step := range steps {
+			if len(steps) > 1 || len(args) < 3 {
+				fmt.Printf("%s step %d: %s\n", marker(step.Status), step.Index, strings.Join(step.Command, " "))
+			}
+			if step.Status == core.PENDING || step.Status == "skipped" {
+				continue
+			}
+			res, err := c.do("GET", httpinterface.API_V1_PREFIX+"/builds/"+strconv.FormatInt(id, 10)+"/steps/"+strconv.Itoa(step.Index)+"/log", nil)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			io.Copy(os.Stdout, res.Body)
+			res.Body.Close()
+			if step.Status == core.FAILED && step.Description != "" {
+				fmt.Println(step.Description)
+			}
+		}
+		if build.Status == core.FAILED {
+			return 1
+		}
+		return 0
+	}
+}
+
+// watchCommand - hook watch <project>, tails live status of project pipeline printing step progress
+func watchCommand(fs *flag.FlagSet) func(c *client, args []string) int {
+	pipeline := fs.String("pipeline", "", "pipeline to watch, default pipeline of project when empty")
+	once := fs.Bool("once", false, "exit once a build finishes, exit code tells if it succeeded")
+	return func(c *client, args []string) int {
+		if len(args) != 1 {
+			fs.Usage()
+			return 2
+		}
+		project := args[0]
+		query := url.Values{}
+		if *pipeline != "" {
+			query.Set("pipeline", *pipeline)
+		}
+		w := &watcher{}
+
+		// last build is shown first, live feed only sends what happens from now on
+		statusQuery := url.Values{"format": {"json"}}
+		if *pipeline != "" {
+			statusQuery.Set("pipeline", *pipeline)
+		}
+		var last liveState
+		if err := c.getJSON("/"+url.PathEscape(project)+"/status", statusQuery, &last); err == nil {
+			w.print(last)
+		}
+
+		wsURL := "ws" + strings.TrimPrefix(c.server, "http") + "/" + url.PathEscape(project) + "/livestatus"
+		if len(query) > 0 {
+			wsURL += "?" + query.Encode()
+		}
+		conn, res, err := websocket.DefaultDialer.Dial(wsURL, c.header())
+		if err != nil {
+			if res != nil {
+				err = fmt.Errorf("%s", responseError(res))
+			}
+			fmt.Fprintf(os.Stderr, "watching %s: %v\n", project, err)
+			return 1
+		}
+		defer conn.Close()
+		fmt.Printf("watching %s, waiting for builds\n", project)
+		for {
+			var state liveState
+			if err := conn.ReadJSON(&state); err != nil {
+				fmt.Fprintf(os.Stderr, "live feed closed: %v\n", err)
+				return 1
+			}
+			w.print(state)
+			if *once && (state.BuildStatus == core.SUCCESS || state.BuildStatus == core.FAILED) {
+				if state.BuildStatus == core.FAILED {
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+}
+
+// watcher prints what changed since the previous state of a build
+type watcher struct {
+	build   int64
+	printed int
+	running int
+	done    bool
+}
+
+func (w *watcher) print(state liveState) {
+	if state.ID != w.build {
+		w.build, w.printed, w.running, w.done = state.ID, 0, -1, false
+		trigger := state.Trigger.Event
+		if state.Trigger.Ref != "" {
+			trigger += " " + state.Trigger.Ref
+		}
+		fmt.Printf("build #%d of pipeline %s (%s)\n", state.ID, state.Pipeline, trigger)
+	}
+	for ; w.printed < len(state.StepResults); w.printed++ {
+		i := w.printed
+		result := state.StepResults[i]
+		command := ""
+		if i < len(state.Steps) {
+			command = strings.Join(state.Steps[i], " ")
+		}
+		status := core.SUCCESS
+		if state.stepFailed(i) {
+			status = core.FAILED
+		}
+		fmt.Printf("  %s %s (%.1fs)\n", marker(status), command, result.Duration)
+		if status == core.FAILED {
+			fmt.Printf("    %s\n", result.Description)
+			for _, line := range lastLines(result.Output, 10) {
+				fmt.Printf("    | %s\n", line)
+			}
+		}
+	}
+	if state.BuildStatus == core.PENDING && w.printed < len(state.Steps) && w.running != w.printed {
+		w.running = w.printed
+		fmt.Printf("  %s %s ...\n", marker(core.PENDING), strings.Join(state.Steps[w.printed], " "))
+	}
+	if (state.BuildStatus == core.SUCCESS || state.BuildStatus == core.FAILED) && !w.done {
+		w.done = true
+		fmt.Printf("%s build #%d %s\n", marker(state.BuildStatus), state.ID, state.BuildStatus)
+	}
+}
+
+func lastLines(output string, n int) []string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"ghhooks.com/hook/core"
+)
+
+// commands - subcommands run instead of the server when given as first argument, each returns exit code
+var commands = map[string]func(args []string) int{
+	"validate": validateCommand,
+	"explain":  explainCommand,
+	"config":   configCommand,
+
+	// clients of a remote server
+	"trigger": clientCommand("trigger", "<project>", triggerCommand),
+	"status":  clientCommand("status", "[project]", statusCommand),
+	"logs":    clientCommand("logs", "<project> [build] [step]", logsCommand),
+	"watch":   clientCommand("watch", "<project>", watchCommand),
+}
+
+// usage - flags of the server along with subcommands
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintln(flag.CommandLine.Output())
+	commandUsage()
+}
+
+func commandUsage() {
+	fmt.Fprint(flag.CommandLine.Output(), `Commands:
+  validate -config file       report config problems
+  explain -config file        print pipelines of every project and what triggers them
+  config convert -config file -to toml|yaml|json
+                              translate config between formats
+  trigger <project>           queue a build on a remote server
+  status [project]            last builds of a remote server
+  logs <project> [build] [step]
+                              output of build steps, of the latest build by default
+  watch <project>             follow builds of project live
+`)
+}
+
+// configFlags - -config and -config-format flags of the server and subcommands
+func configFlags(fs *flag.FlagSet) (*string, *string) {
+	location := fs.String("config", "example.toml", "location of config file, or directory whose *.toml, *.yaml and *.json files are merged")
+	format := fs.String("config-format", "", "format of config file (toml, yaml or json), told by extension when empty")
+	return location, format
+}
+
+// useConfigFormat - sets format config file is read with
+func useConfigFormat(format string) error {
+	if format != "" && !core.ValidFormat(format) {
+		return fmt.Errorf("unknown config format %s, expected toml, yaml or json", format)
+	}
+	core.ConfigFormat = format
+	return nil
+}
+
+// validateCommand - reports config problems, exits with 1 when there are any
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFileLocation, configFormat := configFlags(fs)
+	fs.Parse(args)
+	if err := useConfigFormat(*configFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	_, problems, err := core.LoadConfig(*configFileLocation)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configFileLocation, err)
+		return 1
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", *configFileLocation, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found\n", len(problems))
+		return 1
+	}
+	fmt.Printf("%s: ok\n", *configFileLocation)
+	return 0
+}
+
+// explainCommand - prints the pipelines of every project and what triggers them, legacy keys included
+func explainCommand(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	configFileLocation, configFormat := configFlags(fs)
+	fs.Parse(args)
+	if err := useConfigFormat(*configFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	conf, _, err := core.LoadConfig(*configFileLocation)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configFileLocation, err)
+		return 1
+	}
+	projectNames := make([]string, 0, len(conf.Project))
+	for name := range conf.Project {
+		projectNames = append(projectNames, name)
+	}
+	sort.Strings(projectNames)
+	for _, projectName := range projectNames {
+		project := conf.Project[projectName]
+		fmt.Printf("project %s (cwd %s)\n", projectName, project.Cwd)
+		if project.StepTimeout != 0 {
+			fmt.Printf("  stepTimeout: %ds\n", project.StepTimeout)
+		}
+		if project.QueueSize != 0 {
+			fmt.Printf("  queueSize: %d\n", project.QueueSize)
+		}
+		if len(project.Shell) > 0 {
+			fmt.Printf("  shell: %s\n", strings.Join(project.Shell, " "))
+		}
+		if len(project.Env) > 0 {
+			// only names, values may be secrets
+			names := make([]string, 0, len(project.Env))
+			for name := range project.Env {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("  env: %s\n", strings.Join(names, ", "))
+		}
+		if project.PipelineFile != "" {
+			fmt.Printf("  pipelineFile: %s, allowed commands: %s, allowed env: %s\n", project.PipelineFile,
+				strings.Join(project.AllowedCommands, ", "), strings.Join(project.AllowedEnv, ", "))
+		}
+		pipelines := project.Pipelines()
+		for _, name := range project.PipelineNames() {
+			pipeline := pipelines[name]
+			fmt.Printf("  pipeline %s\n", name)
+			fmt.Printf("    on: %s\n", strings.Join(pipeline.On, ", "))
+			if len(pipeline.Branches) > 0 {
+				fmt.Printf("    branches: %s\n", strings.Join(pipeline.Branches, ", "))
+			}
+			if len(pipeline.Tags) > 0 {
+				fmt.Printf("    tags: %s\n", strings.Join(pipeline.Tags, ", "))
+			}
+			if pipeline.Schedule != "" {
+				fmt.Printf("    schedule: %s\n", pipeline.Schedule)
+			}
+			for i, step := range pipeline.Steps {
+				fmt.Printf("    %d. %s\n", i+1, strings.Join(step, " "))
+			}
+		}
+	}
+	return 0
+}
+
+// configCommand - hook config convert -config file.toml -to yaml, prints converted config or writes it to -o
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "convert" {
+		fmt.Fprintln(os.Stderr, "usage: hook config convert -config file -to toml|yaml|json [-o file]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config convert", flag.ExitOnError)
+	configFileLocation := fs.String("config", "example.toml", "location of config file to convert")
+	from := fs.String("from", "", "format of config file (toml, yaml or json), told by extension when empty")
+	to := fs.String("to", "", "format to convert to (toml, yaml or json), told by extension of -o when empty")
+	out := fs.String("o", "", "file to write converted config to, stdout when empty")
+	fs.Parse(args[1:])
+
+	if *from == "" {
+		*from = core.FormatOf(*configFileLocation)
+	}
+	if *to == "" && *out != "" {
+		*to = core.FormatOf(*out)
+	}
+	if !core.ValidFormat(*to) {
+		fmt.Fprintln(os.Stderr, "-to should be one of toml, yaml or json")
+		return 2
+	}
+	b, err := os.ReadFile(*configFileLocation)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	converted, err := core.ConvertConfig(b, *from, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configFileLocation, err)
+		return 1
+	}
+	if *out == "" {
+		os.Stdout.Write(converted)
+		return 0
+	}
+	if err := os.WriteFile(*out, converted, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
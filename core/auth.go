@@ -0,0 +1,117 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// access levels, readUsers, readTokens and share links only ever grant read access
+const (
+	ACCESS_READ    = "read"
+	ACCESS_CONTROL = "control"
+)
+
+// AuthConf guards status pages, live feeds, history and control endpoints. configured globally under [auth]
+// and per project under [project.x.auth], credentials of global auth are accepted for every project
+type AuthConf struct {
+	// username to bcrypt hash of password, for http basic auth
+	Users map[string]string `toml:"users"`
+	// bearer api tokens
+	Tokens []string `toml:"tokens"`
+	// like users and tokens, but only for status pages, live feeds, history and deliveries
+	ReadUsers  map[string]string `toml:"readUsers"`
+	ReadTokens []string          `toml:"readTokens"`
+	// key share links are signed with, share links are disabled when empty
+	ShareSecret string `toml:"shareSecret"`
+	// origins allowed to open live status websockets and send control requests with basic auth besides the
+	// server itself, "*" allows all (global only)
+	AllowedOrigins []string `toml:"allowedOrigins"`
+}
+
+// Enabled - auth is only enforced when users or tokens are configured
+func (a AuthConf) Enabled() bool {
+	return len(a.Users) > 0 || len(a.Tokens) > 0 || len(a.ReadUsers) > 0 || len(a.ReadTokens) > 0
+}
+
+// Validate - checks that every password is a bcrypt hash
+func (a AuthConf) Validate() error {
+	for _, users := range []map[string]string{a.Users, a.ReadUsers} {
+		for user, hash := range users {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return fmt.Errorf("password of user %s is not a bcrypt hash: %v", user, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckPassword - checks basic auth credentials, users of readUsers only get read access
+func (a AuthConf) CheckPassword(user string, password string, access string) bool {
+	hash, ok := a.Users[user]
+	if !ok && access == ACCESS_READ {
+		hash, ok = a.ReadUsers[user]
+	}
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// CheckToken - checks bearer token, readTokens only get read access
+func (a AuthConf) CheckToken(token string, access string) bool {
+	tokens := a.Tokens
+	if access == ACCESS_READ {
+		tokens = append(append([]string{}, a.Tokens...), a.ReadTokens...)
+	}
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SignShare - share token that gives read access to project until expires, formatted as <expires>.<signature>
+func (a AuthConf) SignShare(project string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return unix + "." + a.shareSignature(project, unix)
+}
+
+// CheckShare - verifies share token of project and that it has not expired
+func (a AuthConf) CheckShare(project string, share string, now time.Time) bool {
+	if a.ShareSecret == "" {
+		return false
+	}
+	unix, signature, ok := strings.Cut(share, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(a.shareSignature(project, unix)))
+}
+
+func (a AuthConf) shareSignature(project string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(a.ShareSecret))
+	mac.Write([]byte(project + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ProjectAuth - auth of project, global auth when project does not configure its own
+func ProjectAuth(projectName string) AuthConf {
+	conf := Conf()
+	if project, ok := conf.Project[projectName]; ok && project.Auth != nil {
+		return *project.Auth
+	}
+	return conf.Auth
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func TestAuthAccess(t *testing.T) {
+	auth := AuthConf{
+		Users:      map[string]string{"admin": hashPassword(t, "admin-password")},
+		Tokens:     []string{"control-token"},
+		ReadUsers:  map[string]string{"viewer": hashPassword(t, "viewer-password")},
+		ReadTokens: []string{"read-token"},
+	}
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		token    string
+		access   string
+		want     bool
+	}{
+		{name: "user reads", user: "admin", password: "admin-password", access: ACCESS_READ, want: true},
+		{name: "user controls", user: "admin", password: "admin-password", access: ACCESS_CONTROL, want: true},
+		{name: "wrong password", user: "admin", password: "viewer-password", access: ACCESS_READ, want: false},
+		{name: "read user reads", user: "viewer", password: "viewer-password", access: ACCESS_READ, want: true},
+		{name: "read user cannot control", user: "viewer", password: "viewer-password", access: ACCESS_CONTROL, want: false},
+		{name: "token reads", token: "control-token", access: ACCESS_READ, want: true},
+		{name: "token controls", token: "control-token", access: ACCESS_CONTROL, want: true},
+		{name: "read token reads", token: "read-token", access: ACCESS_READ, want: true},
+		{name: "read token cannot control", token: "read-token", access: ACCESS_CONTROL, want: false},
+		{name: "unknown token", token: "other-token", access: ACCESS_READ, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			if tt.token != "" {
+				got = auth.CheckToken(tt.token, tt.access)
+			} else {
+				got = auth.CheckPassword(tt.user, tt.password, tt.access)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthEnabled(t *testing.T) {
+	if (AuthConf{ShareSecret: "x"}).Enabled() {
+		t.Error("auth with only shareSecret is enabled")
+	}
+	if !(AuthConf{ReadTokens: []string{"read-token"}}).Enabled() {
+		t.Error("auth with only readTokens is not enabled")
+	}
+}
+
+func TestShareSigning(t *testing.T) {
+	auth := AuthConf{ShareSecret: "secret"}
+	now := time.Unix(1700000000, 0)
+	share := auth.SignShare("p", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		auth    AuthConf
+		project string
+		share   string
+		now     time.Time
+		want    bool
+	}{
+		{name: "valid", auth: auth, project: "p", share: share, now: now, want: true},
+		{name: "at expiry", auth: auth, project: "p", share: share, now: now.Add(time.Hour), want: true},
+		{name: "expired", auth: auth, project: "p", share: share, now: now.Add(time.Hour + time.Second), want: false},
+		{name: "other project", auth: auth, project: "q", share: share, now: now, want: false},
+		{name: "other secret", auth: AuthConf{ShareSecret: "other"}, project: "p", share: share, now: now, want: false},
+		{name: "share links disabled", auth: AuthConf{}, project: "p", share: AuthConf{}.SignShare("p", now.Add(time.Hour)), now: now, want: false},
+		{name: "expiry extended", auth: auth, project: "p", share: "1800000000" + share[strings.Index(share, "."):], now: now, want: false},
+		{name: "malformed", auth: auth, project: "p", share: "garbage", now: now, want: false},
+		{name: "invalid expiry", auth: auth, project: "p", share: "soon.x", now: now, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.auth.CheckShare(tt.project, tt.share, tt.now); got != tt.want {
+				t.Errorf("CheckShare(%q, %q) = %v, want %v", tt.project, tt.share, got, tt.want)
+			}
+		})
+	}
+}
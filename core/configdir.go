@@ -0,0 +1,67 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// parseConfigDir - every *.toml, *.yaml and *.json file of dir contributes projects, other sections can be
+// in any file but only in one of them. files are read in lexical order
+func parseConfigDir(dir string) (Doc, []Problem, error) {
+	doc := Doc{Project: make(map[string]Project)}
+	problems := make([]Problem, 0)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return doc, nil, err
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if _, ok := configExtensions[strings.ToLower(filepath.Ext(entry.Name()))]; ok && !entry.IsDir() {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return doc, nil, fmt.Errorf("%s: no *.toml, *.yaml or *.json files found", dir)
+	}
+	sort.Strings(files)
+
+	projectFiles := make(map[string]string)
+	sectionFiles := make(map[string]string)
+	for _, file := range files {
+		name := filepath.Base(file)
+		part, md, err := decodeConfigFile(file, FormatOf(file))
+		if err != nil {
+			return doc, nil, fmt.Errorf("%s: %v", name, err)
+		}
+		problems = append(problems, unknownKeys(md, name)...)
+
+		for projectName, project := range part.Project {
+			if other, ok := projectFiles[projectName]; ok {
+				return doc, nil, fmt.Errorf("project %s is defined in both %s and %s", projectName, other, name)
+			}
+			projectFiles[projectName] = name
+			doc.Project[projectName] = project
+		}
+
+		// copying the other top level keys that file sets
+		docValue := reflect.ValueOf(&doc).Elem()
+		partValue := reflect.ValueOf(part)
+		for i := 0; i < docValue.NumField(); i++ {
+			key, _, _ := strings.Cut(docValue.Type().Field(i).Tag.Get("toml"), ",")
+			if key == "" || key == "project" || !md.IsDefined(key) {
+				continue
+			}
+			if other, ok := sectionFiles[key]; ok {
+				return doc, nil, fmt.Errorf("%s is defined in both %s and %s", key, other, name)
+			}
+			sectionFiles[key] = name
+			docValue.Field(i).Set(partValue.Field(i))
+		}
+	}
+	return doc, problems, nil
+}
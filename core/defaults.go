@@ -0,0 +1,79 @@
+package core
+
+import "strings"
+
+// DEFAULT_QUEUE_SIZE - builds a project queue holds when neither project nor [defaults] set queueSize
+const DEFAULT_QUEUE_SIZE = 25
+
+// DEFAULT_STEP_TIMEOUT - seconds a step may run when neither pipeline, project nor [defaults] set stepTimeout
+const DEFAULT_STEP_TIMEOUT = 600
+
+// Defaults are inherited by every project that does not set them itself, configured under [defaults]
+type Defaults struct {
+	StepTimeout int `toml:"stepTimeout"`
+	// steps are run by shell as one command line, e.g. ["sh", "-c"], when set
+	Shell []string `toml:"shell"`
+	// environment variables of steps, project env is merged over it
+	Env map[string]string `toml:"env"`
+	// number of builds that can wait in a project queue
+	QueueSize int `toml:"queueSize"`
+}
+
+// applyDefaults - fills in project values that are not set from [defaults]
+func (d *Doc) applyDefaults() {
+	for projectName, project := range d.Project {
+		if project.StepTimeout == 0 {
+			project.StepTimeout = d.Defaults.StepTimeout
+		}
+		if len(project.Shell) == 0 {
+			project.Shell = d.Defaults.Shell
+		}
+		if project.QueueSize == 0 {
+			project.QueueSize = d.Defaults.QueueSize
+		}
+		if len(d.Defaults.Env) > 0 {
+			env := make(map[string]string, len(d.Defaults.Env)+len(project.Env))
+			for k, v := range d.Defaults.Env {
+				env[k] = v
+			}
+			for k, v := range project.Env {
+				env[k] = v
+			}
+			project.Env = env
+		}
+		d.Project[projectName] = project
+	}
+}
+
+// queueSize - size of project queue, DEFAULT_QUEUE_SIZE when not configured
+func (p Project) queueSize() int {
+	if p.QueueSize > 0 {
+		return p.QueueSize
+	}
+	return DEFAULT_QUEUE_SIZE
+}
+
+// command - program and arguments step is run with. with shell set a single element step is the command
+// line of shell as written, arguments of longer steps are quoted so they reach the command unchanged
+func (p Project) command(step []string) (string, []string) {
+	if len(p.Shell) == 0 {
+		return step[0], step[1:]
+	}
+	line := step[0]
+	if len(step) > 1 {
+		quoted := make([]string, len(step))
+		for i, arg := range step {
+			quoted[i] = shellQuote(arg)
+		}
+		line = strings.Join(quoted, " ")
+	}
+	return p.Shell[0], append(append([]string{}, p.Shell[1:]...), line)
+}
+
+// shellQuote - quotes arg for posix shells, arguments made only of safe characters are left as they are
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
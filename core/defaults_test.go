@@ -0,0 +1,46 @@
+package core
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell []string
+		step  []string
+		want  []string
+	}{
+		{name: "without shell", step: []string{"git", "log", "-1"}, want: []string{"git", "log", "-1"}},
+		{name: "command line", shell: []string{"sh", "-c"}, step: []string{"make && make install"}, want: []string{"sh", "-c", "make && make install"}},
+		{name: "plain arguments", shell: []string{"sh", "-c"}, step: []string{"git", "log", "-1"}, want: []string{"sh", "-c", "git log -1"}},
+		{name: "quoted arguments", shell: []string{"sh", "-c"}, step: []string{"echo", "a b", "$HOME", "it's", ""}, want: []string{"sh", "-c", `echo 'a b' '$HOME' 'it'\''s' ''`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := Project{Shell: tt.shell}.command(tt.step)
+			if got := append([]string{name}, args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandShellArguments(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	step := []string{"printf", "%s\\n", "a b", "$HOME", "it's", "x;y", "*"}
+	name, args := Project{Shell: []string{"sh", "-c"}}.command(step)
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(step[2:], "\n") + "\n"
+	if string(out) != want {
+		t.Errorf("shell got %q, want %q", out, want)
+	}
+}
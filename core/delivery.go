@@ -0,0 +1,134 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signature verdicts of a delivery
+const (
+	SIGNATURE_VERIFIED = "verified"
+	SIGNATURE_FAILED   = "failed"
+	SIGNATURE_MISSING  = "unsigned"
+)
+
+// verdicts of a delivery
+const (
+	VERDICT_QUEUED     = "queued"
+	VERDICT_SKIPPED    = "skipped"
+	VERDICT_PONG       = "pong"
+	VERDICT_REJECTED   = "rejected"
+	VERDICT_QUEUE_FULL = "queue_full"
+)
+
+type DeliveryConf struct {
+	Limit  int `toml:"limit"`
+	MaxAge int `toml:"maxAge"`
+}
+
+// Delivery is a webhook request as it was received along with what was done with it
+type Delivery struct {
+	ID           int64       `json:"id"`
+	GUID         string      `json:"guid"`
+	Project      string      `json:"project"`
+	Event        string      `json:"event"`
+	ReceivedAt   time.Time   `json:"receivedAt"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	Signature    string      `json:"signature"`
+	Verdict      string      `json:"verdict"`
+	Decision     string      `json:"decision"`
+	StatusCode   int         `json:"statusCode"`
+	BuildIDs     []int64     `json:"buildIds,omitempty"`
+	RedeliveryOf int64       `json:"redeliveryOf,omitempty"`
+}
+
+// Summary - delivery without headers and body
+func (d Delivery) Summary() Delivery {
+	d.Headers = nil
+	d.Body = ""
+	return d
+}
+
+// DeliveryLog keeps deliveries in memory, oldest first, entries are dropped when there are
+// more than limit of them or when they are older than maxAge
+type DeliveryLog struct {
+	mu      sync.RWMutex
+	lastID  int64
+	entries []Delivery
+	limit   int
+	maxAge  time.Duration
+}
+
+func NewDeliveryLog(conf DeliveryConf) *DeliveryLog {
+	limit := conf.Limit
+	if limit == 0 {
+		limit = 200
+	}
+	maxAge := 7 * 24 * time.Hour
+	if conf.MaxAge != 0 {
+		maxAge = time.Duration(conf.MaxAge) * time.Second
+	}
+	return &DeliveryLog{
+		entries: make([]Delivery, 0),
+		limit:   limit,
+		maxAge:  maxAge,
+	}
+}
+
+// Add - stores delivery and returns the id assigned to it
+func (dl *DeliveryLog) Add(d Delivery) int64 {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.lastID++
+	d.ID = dl.lastID
+	dl.entries = append(dl.entries, d)
+
+	cutoff := time.Now().Add(-dl.maxAge)
+	drop := 0
+	for drop < len(dl.entries) && (len(dl.entries)-drop > dl.limit || dl.entries[drop].ReceivedAt.Before(cutoff)) {
+		drop++
+	}
+	if drop > 0 {
+		dl.entries = append([]Delivery(nil), dl.entries[drop:]...)
+	}
+	return d.ID
+}
+
+func (dl *DeliveryLog) Get(id int64) (Delivery, bool) {
+	dl.mu.RLock()
+	defer dl.mu.RUnlock()
+	for _, d := range dl.entries {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Delivery{}, false
+}
+
+// List - returns summaries of stored deliveries newest first, filtered by project when it is not empty
+func (dl *DeliveryLog) List(project string) []Delivery {
+	dl.mu.RLock()
+	defer dl.mu.RUnlock()
+	list := make([]Delivery, 0, len(dl.entries))
+	for i := len(dl.entries) - 1; i >= 0; i-- {
+		if project != "" && dl.entries[i].Project != project {
+			continue
+		}
+		list = append(list, dl.entries[i].Summary())
+	}
+	return list
+}
+
+// DeliveryHeaders - keeps only the headers that github sets, so credentials sent to the server are never stored
+func DeliveryHeaders(header http.Header) http.Header {
+	kept := make(http.Header)
+	for k, v := range header {
+		if strings.HasPrefix(k, "X-Github-") || strings.HasPrefix(k, "X-Hub-") || k == "Content-Type" || k == "User-Agent" {
+			kept[k] = append([]string(nil), v...)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeliveryLogRetention(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		conf     DeliveryConf
+		received []time.Duration
+		kept     []int64
+	}{
+		{name: "under limit", conf: DeliveryConf{Limit: 3}, received: []time.Duration{0, 0}, kept: []int64{2, 1}},
+		{name: "oldest dropped over limit", conf: DeliveryConf{Limit: 2}, received: []time.Duration{0, 0, 0, 0}, kept: []int64{4, 3}},
+		{name: "older than maxAge dropped", conf: DeliveryConf{MaxAge: 60}, received: []time.Duration{-2 * time.Minute, -90 * time.Second, -30 * time.Second, 0}, kept: []int64{4, 3}},
+		{name: "default maxAge is a week", conf: DeliveryConf{}, received: []time.Duration{-8 * 24 * time.Hour, -6 * 24 * time.Hour}, kept: []int64{2}},
+		{name: "limit and maxAge", conf: DeliveryConf{Limit: 1, MaxAge: 60}, received: []time.Duration{-2 * time.Minute, 0, 0}, kept: []int64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := NewDeliveryLog(tt.conf)
+			for i, age := range tt.received {
+				if id := dl.Add(Delivery{ReceivedAt: now.Add(age), Project: "p"}); id != int64(i+1) {
+					t.Fatalf("Add() = %d, want %d", id, i+1)
+				}
+			}
+			ids := make([]int64, 0)
+			for _, d := range dl.List("") {
+				ids = append(ids, d.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.kept) {
+				t.Errorf("kept %v, want %v", ids, tt.kept)
+			}
+			if _, ok := dl.Get(1); ok != (tt.kept[len(tt.kept)-1] == 1) {
+				t.Errorf("Get(1) found = %v", ok)
+			}
+		})
+	}
+}
+
+func TestDeliveryLogList(t *testing.T) {
+	dl := NewDeliveryLog(DeliveryConf{})
+	dl.Add(Delivery{Project: "a", ReceivedAt: time.Now(), Body: "{}", Headers: http.Header{"X-Github-Event": {"push"}}})
+	dl.Add(Delivery{Project: "b", ReceivedAt: time.Now()})
+
+	list := dl.List("a")
+	if len(list) != 1 || list[0].Project != "a" {
+		t.Fatalf("List(a) = %v, want the delivery of a", list)
+	}
+	if list[0].Body != "" || list[0].Headers != nil {
+		t.Error("List() returns bodies and headers, want summaries")
+	}
+	if d, ok := dl.Get(list[0].ID); !ok || d.Body != "{}" {
+		t.Errorf("Get() = %v %v, want delivery with body", d, ok)
+	}
+}
+
+func TestDeliveryHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Github-Event":      {"push"},
+		"X-Hub-Signature-256": {"sha256=x"},
+		"Content-Type":        {"application/json"},
+		"Authorization":       {"Bearer secret"},
+		"Cookie":              {"session=x"},
+	}
+	want := http.Header{
+		"X-Github-Event":      {"push"},
+		"X-Hub-Signature-256": {"sha256=x"},
+		"Content-Type":        {"application/json"},
+	}
+	if got := DeliveryHeaders(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("DeliveryHeaders() = %v, want %v", got, want)
+	}
+}
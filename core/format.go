@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// config formats, toml is assumed for unknown extensions
+const (
+	FORMAT_TOML = "toml"
+	FORMAT_YAML = "yaml"
+	FORMAT_JSON = "json"
+)
+
+// ConfigFormat - format of config file when it is not to be told by extension, set by -config-format
+var ConfigFormat string
+
+// configExtensions - files of a config directory that are read, and their format
+var configExtensions = map[string]string{
+	".toml": FORMAT_TOML,
+	".yaml": FORMAT_YAML,
+	".yml":  FORMAT_YAML,
+	".json": FORMAT_JSON,
+}
+
+// FormatOf - format of config file by its extension
+func FormatOf(file string) string {
+	if format, ok := configExtensions[strings.ToLower(filepath.Ext(file))]; ok {
+		return format
+	}
+	return FORMAT_TOML
+}
+
+// ValidFormat - reports if format is one of toml, yaml or json
+func ValidFormat(format string) bool {
+	return format == FORMAT_TOML || format == FORMAT_YAML || format == FORMAT_JSON
+}
+
+// decodeConfig - yaml and json are turned into toml before they are decoded, so that every format
+// uses the same keys and unknown keys are reported the same way
+func decodeConfig(b []byte, format string, v any) (toml.MetaData, error) {
+	if format == FORMAT_TOML {
+		return toml.Decode(string(b), v)
+	}
+	tree, err := decodeTree(b, format)
+	if err != nil {
+		return toml.MetaData{}, err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(tree); err != nil {
+		return toml.MetaData{}, err
+	}
+	md, err := toml.Decode(buf.String(), v)
+	if err != nil {
+		// line numbers are of the toml yaml or json was turned into, only the key means something
+		err = fmt.Errorf("%s", tomlPosition.ReplaceAllString(err.Error(), "key $1"))
+	}
+	return md, err
+}
+
+var tomlPosition = regexp.MustCompile(`toml: line \d+ \(last key ("[^"]*")\)`)
+
+// decodeTree - decodes config of any format into plain maps, slices and values
+func decodeTree(b []byte, format string) (map[string]any, error) {
+	tree := make(map[string]any)
+	var err error
+	switch format {
+	case FORMAT_TOML:
+		_, err = toml.Decode(string(b), &tree)
+	case FORMAT_YAML:
+		err = yaml.Unmarshal(b, &tree)
+	case FORMAT_JSON:
+		d := json.NewDecoder(bytes.NewReader(b))
+		// numbers are kept as written so that integers stay integers
+		d.UseNumber()
+		err = d.Decode(&tree)
+	default:
+		err = fmt.Errorf("unknown config format %s, expected toml, yaml or json", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizeTree(tree).(map[string]any), nil
+}
+
+// normalizeTree - converts values to the types toml encodes, nulls are dropped since toml has none
+func normalizeTree(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, value := range v {
+			if value != nil {
+				m[k] = normalizeTree(value)
+			}
+		}
+		return m
+	case []any:
+		s := make([]any, 0, len(v))
+		for _, value := range v {
+			if value != nil {
+				s = append(s, normalizeTree(value))
+			}
+		}
+		return s
+	case []map[string]any:
+		s := make([]any, 0, len(v))
+		for _, value := range v {
+			s = append(s, normalizeTree(value))
+		}
+		return s
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case int:
+		return int64(v)
+	default:
+		return v
+	}
+}
+
+// ConvertConfig - translates config between formats, values are kept as written so secret
+// references are not resolved
+func ConvertConfig(b []byte, from string, to string) ([]byte, error) {
+	tree, err := decodeTree(b, from)
+	if err != nil {
+		return nil, err
+	}
+	switch to {
+	case FORMAT_TOML:
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(tree)
+		return buf.Bytes(), err
+	case FORMAT_YAML:
+		return yaml.Marshal(tree)
+	case FORMAT_JSON:
+		out, err := json.MarshalIndent(tree, "", "  ")
+		return append(out, '\n'), err
+	default:
+		return nil, fmt.Errorf("unknown config format %s, expected toml, yaml or json", to)
+	}
+}
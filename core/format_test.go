@@ -0,0 +1,179 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const formatTOML = `basePath = "/hooks"
+
+[history]
+limit = 10
+
+[project.p]
+branch = "main"
+secret = "${env:P_SECRET}"
+cwd = "/srv/p"
+steps = [["make"], ["make", "install"]]
+stepTimeout = 60
+env = { NODE_ENV = "production" }
+
+[project.p.pipeline.deploy]
+on = ["push"]
+steps = [["./deploy.sh"]]
+`
+
+const formatYAML = `basePath: /hooks
+history:
+  limit: 10
+project:
+  p:
+    branch: main
+    secret: ${env:P_SECRET}
+    cwd: /srv/p
+    steps: [[make], [make, install]]
+    stepTimeout: 60
+    env:
+      NODE_ENV: production
+    pipeline:
+      deploy:
+        on: [push]
+        steps: [[./deploy.sh]]
+`
+
+const formatJSON = `{
+  "basePath": "/hooks",
+  "history": {"limit": 10},
+  "project": {
+    "p": {
+      "branch": "main",
+      "secret": "${env:P_SECRET}",
+      "cwd": "/srv/p",
+      "steps": [["make"], ["make", "install"]],
+      "stepTimeout": 60,
+      "env": {"NODE_ENV": "production"},
+      "pipeline": {"deploy": {"on": ["push"], "steps": [["./deploy.sh"]]}}
+    }
+  }
+}
+`
+
+func TestDecodeConfig(t *testing.T) {
+	var want Doc
+	if _, err := decodeConfig([]byte(formatTOML), FORMAT_TOML, &want); err != nil {
+		t.Fatal(err)
+	}
+	if want.Project["p"].StepTimeout != 60 || want.History.Limit != 10 {
+		t.Fatalf("toml decoded to %+v", want)
+	}
+
+	tests := []struct {
+		format string
+		config string
+	}{
+		{FORMAT_YAML, formatYAML},
+		{FORMAT_JSON, formatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var doc Doc
+			md, err := decodeConfig([]byte(tt.config), tt.format, &doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(doc, want) {
+				t.Errorf("decoded to %+v, want %+v", doc, want)
+			}
+			if problems := unknownKeys(md, ""); len(problems) != 0 {
+				t.Errorf("unknown keys %v", problems)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		config  string
+		unknown []string
+		err     string
+	}{
+		{name: "yaml unknown key", format: FORMAT_YAML, config: "project:\n  p:\n    branch: main\n    stepTimout: 5\n", unknown: []string{"project.p.stepTimout"}},
+		{name: "json unknown key", format: FORMAT_JSON, config: `{"histroy": {"limit": 1}}`, unknown: []string{"histroy", "histroy.limit"}},
+		{name: "yaml nulls are dropped", format: FORMAT_YAML, config: "basePath: null\nproject:\n  p:\n    steps: [[make, null]]\n", unknown: []string{}},
+		{name: "yaml wrong type", format: FORMAT_YAML, config: "project:\n  p:\n    stepTimeout: soon\n", err: `key "project.p.stepTimeout"`},
+		{name: "json float for int", format: FORMAT_JSON, config: `{"history": {"limit": 1.5}}`, err: `key "history.limit"`},
+		{name: "invalid yaml", format: FORMAT_YAML, config: "project: [", err: "yaml"},
+		{name: "invalid json", format: FORMAT_JSON, config: "{", err: "EOF"},
+		{name: "unknown format", format: "ini", config: "", err: "unknown config format ini"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc Doc
+			md, err := decodeConfig([]byte(tt.config), tt.format, &doc)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || strings.Contains(err.Error(), "toml: line") {
+					t.Fatalf("error = %v, want error containing %q and no toml line numbers", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := problemKeys(unknownKeys(md, "")); !reflect.DeepEqual(got, tt.unknown) {
+				t.Errorf("unknown keys = %v, want %v", got, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestConvertConfig(t *testing.T) {
+	want, err := decodeTree([]byte(formatTOML), FORMAT_TOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, from := range []string{FORMAT_TOML, FORMAT_YAML, FORMAT_JSON} {
+		for _, to := range []string{FORMAT_TOML, FORMAT_YAML, FORMAT_JSON} {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				config := map[string]string{FORMAT_TOML: formatTOML, FORMAT_YAML: formatYAML, FORMAT_JSON: formatJSON}[from]
+				converted, err := ConvertConfig([]byte(config), from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := decodeTree(converted, to)
+				if err != nil {
+					t.Fatalf("converted config does not decode: %v\n%s", err, converted)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("converted to %v, want %v", got, want)
+				}
+				// secret references are copied, not resolved
+				if !strings.Contains(string(converted), "${env:P_SECRET}") {
+					t.Errorf("secret reference is missing from\n%s", converted)
+				}
+			})
+		}
+	}
+	if _, err := ConvertConfig([]byte(formatTOML), FORMAT_TOML, "ini"); err == nil {
+		t.Error("converting to unknown format did not fail")
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"config.toml":      FORMAT_TOML,
+		"config.yaml":      FORMAT_YAML,
+		"config.YML":       FORMAT_YAML,
+		"conf.d/p.json":    FORMAT_JSON,
+		"config":           FORMAT_TOML,
+		"config.toml.bak":  FORMAT_TOML,
+		"/etc/hook/c.yaml": FORMAT_YAML,
+	}
+	for file, want := range tests {
+		if got := FormatOf(file); got != want {
+			t.Errorf("FormatOf(%q) = %s, want %s", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+import "sync"
+
+var healthMu sync.RWMutex
+var configErr error
+
+// SetConfigError - records why config could not be loaded, nil once config loads again
+func SetConfigError(err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	configErr = err
+}
+
+func ConfigError() error {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	return configErr
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrBuildNotFound = errors.New("no build found with given id")
+
+type HistoryConf struct {
+	// builds kept per project
+	Limit int `toml:"limit"`
+}
+
+type historyEntry struct {
+	project string
+	state   JobState
+	done    chan struct{}
+}
+
+// BuildHistory keeps builds by id from the moment they are queued, finished builds of a project are
+// dropped oldest first once there are more than limit of them
+type BuildHistory struct {
+	mu      sync.RWMutex
+	entries map[int64]*historyEntry
+	order   map[string][]int64
+	limit   int
+}
+
+func NewBuildHistory(limit int) *BuildHistory {
+	if limit == 0 {
+		limit = 50
+	}
+	return &BuildHistory{
+		entries: make(map[int64]*historyEntry),
+		order:   make(map[string][]int64),
+		limit:   limit,
+	}
+}
+
+// Add - records a queued build
+func (h *BuildHistory) Add(build Build) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[build.ID] = &historyEntry{
+		project: build.ProjectName,
+		state: JobState{
+			ID:          build.ID,
+			StepResults: make([]Result, 0),
+			BuildStatus: QUEUED,
+			Pipeline:    build.PipelineName,
+			Trigger:     build.Trigger,
+		},
+		done: make(chan struct{}),
+	}
+	ids := append(h.order[build.ProjectName], build.ID)
+	// unfinished builds are never dropped, they can still be waited on
+	kept := make([]int64, 0, len(ids))
+	excess := len(ids) - h.limit
+	for _, id := range ids {
+		if excess > 0 && h.entries[id].state.Finished() {
+			delete(h.entries, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	h.order[build.ProjectName] = kept
+}
+
+// Remove - drops build that could not be queued
+func (h *BuildHistory) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[id]
+	if !ok {
+		return
+	}
+	delete(h.entries, id)
+	ids := h.order[entry.project]
+	for i := range ids {
+		if ids[i] == id {
+			h.order[entry.project] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// Update - stores state of build, waiters are released once the build has finished
+func (h *BuildHistory) Update(state JobState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[state.ID]
+	if !ok {
+		return
+	}
+	entry.state = state
+	if state.Finished() {
+		select {
+		case <-entry.done:
+		default:
+			close(entry.done)
+		}
+	}
+}
+
+// Get - returns build with given id along with project it belongs to
+func (h *BuildHistory) Get(id int64) (string, JobState, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	entry, ok := h.entries[id]
+	if !ok {
+		return "", JobState{}, false
+	}
+	return entry.project, entry.state, true
+}
+
+// List - returns builds of project newest first
+func (h *BuildHistory) List(project string) []JobState {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := h.order[project]
+	list := make([]JobState, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		list = append(list, h.entries[ids[i]].state)
+	}
+	return list
+}
+
+// Running - returns build of project that is currently running
+func (h *BuildHistory) Running(project string) (JobState, bool) {
+	for _, state := range h.List(project) {
+		if state.BuildStatus == PENDING {
+			return state, true
+		}
+	}
+	return JobState{}, false
+}
+
+// Wait - blocks until build has finished or ctx is done, returns last known state of the build
+func (h *BuildHistory) Wait(ctx context.Context, id int64) (JobState, error) {
+	h.mu.RLock()
+	entry, ok := h.entries[id]
+	h.mu.RUnlock()
+	if !ok {
+		return JobState{}, ErrBuildNotFound
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+	}
+	h.mu.RLock()
+	state := entry.state
+	h.mu.RUnlock()
+	if state.Finished() {
+		return state, nil
+	}
+	return state, ctx.Err()
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func addBuilds(h *BuildHistory, project string, n int) []Build {
+	builds := make([]Build, n)
+	for i := range builds {
+		builds[i] = Build{ID: NextBuildID(), ProjectName: project, PipelineName: DEFAULT_PIPELINE}
+		h.Add(builds[i])
+	}
+	return builds
+}
+
+func finish(h *BuildHistory, build Build, status string) {
+	h.Update(JobState{ID: build.ID, BuildStatus: status, Pipeline: build.PipelineName})
+}
+
+func TestBuildHistoryLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		builds   int
+		finished map[int]bool
+		kept     []int
+	}{
+		{name: "under limit", limit: 3, builds: 2, finished: map[int]bool{0: true, 1: true}, kept: []int{0, 1}},
+		{name: "oldest finished dropped", limit: 2, builds: 3, finished: map[int]bool{0: true, 1: true, 2: true}, kept: []int{1, 2}},
+		{name: "unfinished kept", limit: 2, builds: 4, finished: map[int]bool{1: true}, kept: []int{0, 2, 3}},
+		{name: "nothing finished", limit: 1, builds: 3, kept: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewBuildHistory(tt.limit)
+			builds := make([]Build, 0, tt.builds)
+			for i := 0; i < tt.builds; i++ {
+				build := addBuilds(h, "p", 1)[0]
+				builds = append(builds, build)
+				if tt.finished[i] {
+					finish(h, build, SUCCESS)
+				}
+			}
+			list := h.List("p")
+			if len(list) != len(tt.kept) {
+				t.Fatalf("kept %d builds, want %d", len(list), len(tt.kept))
+			}
+			for i, k := range tt.kept {
+				// list is newest first
+				if got := list[len(list)-1-i].ID; got != builds[k].ID {
+					t.Errorf("build %d = %d, want %d", i, got, builds[k].ID)
+				}
+				if _, _, ok := h.Get(builds[k].ID); !ok {
+					t.Errorf("build %d is not found", builds[k].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildHistoryProjects(t *testing.T) {
+	h := NewBuildHistory(1)
+	a := addBuilds(h, "a", 1)[0]
+	finish(h, a, FAILED)
+	addBuilds(h, "b", 2)
+	if project, state, ok := h.Get(a.ID); !ok || project != "a" || state.BuildStatus != FAILED {
+		t.Errorf("Get() = %s %s %v, want a failed", project, state.BuildStatus, ok)
+	}
+	if running, ok := h.Running("b"); ok {
+		t.Errorf("Running() = %d, want none", running.ID)
+	}
+	h.Remove(a.ID)
+	if _, _, ok := h.Get(a.ID); ok || len(h.List("a")) != 0 {
+		t.Error("removed build is still kept")
+	}
+}
+
+func TestBuildHistoryWait(t *testing.T) {
+	h := NewBuildHistory(1)
+	build := addBuilds(h, "p", 1)[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if state, err := h.Wait(ctx, build.ID); err != context.DeadlineExceeded || state.BuildStatus != QUEUED {
+		t.Errorf("Wait() = %s, %v, want queued and deadline exceeded", state.BuildStatus, err)
+	}
+
+	// more builds than limit while one is being waited on
+	go func() {
+		addBuilds(h, "p", 2)
+		h.Update(JobState{ID: build.ID, BuildStatus: PENDING})
+		finish(h, build, SUCCESS)
+	}()
+	state, err := h.Wait(context.Background(), build.ID)
+	if err != nil || state.BuildStatus != SUCCESS {
+		t.Errorf("Wait() = %s, %v, want success", state.BuildStatus, err)
+	}
+	if _, err := h.Wait(context.Background(), -1); err != ErrBuildNotFound {
+		t.Errorf("Wait() of unknown build = %v, want ErrBuildNotFound", err)
+	}
+}
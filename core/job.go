@@ -0,0 +1,278 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+
+	"ghhooks.com/hook/jobqueue"
+)
+
+// Trigger describes the event that caused a build
+type Trigger struct {
+	Event   string `json:"event"`
+	Ref     string `json:"ref,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	PR      int    `json:"pr,omitempty"`
+	Base    string `json:"base,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Build is passed as the only argument of Job when it is enqueued on a project queue
+type Build struct {
+	ID           int64
+	ProjectName  string
+	Project      Project
+	PipelineName string
+	Pipeline     Pipeline
+	Trigger      Trigger
+}
+
+// NewBuild - returns build of project pipeline with a new id
+func NewBuild(projectName string, project Project, pipeline string, trigger Trigger) Build {
+	return Build{
+		ID:           NextBuildID(),
+		ProjectName:  projectName,
+		Project:      project,
+		PipelineName: pipeline,
+		Pipeline:     project.Pipelines()[pipeline],
+		Trigger:      trigger,
+	}
+}
+
+// Enqueue - queues build on its project queue, returns false when the queue is full
+func Enqueue(build Build) bool {
+	// added before enqueueing so that the build can be waited on as soon as it is queued
+	History.Add(build)
+	jq, ok := ProjectQueue(build.ProjectName)
+	if ok {
+		ok = jq.Enqueue(jobqueue.Job{
+			Name:   "build",
+			Action: Job,
+			Args:   []any{build},
+		})
+	}
+	if !ok {
+		History.Remove(build.ID)
+		return false
+	}
+	if _, state, ok := History.Get(build.ID); ok {
+		DashboardFeed.Publish(build.ProjectName, state)
+	}
+	return true
+}
+
+func (b Build) stepTimeout() time.Duration {
+	switch {
+	case b.Pipeline.StepTimeout != 0:
+		return time.Duration(b.Pipeline.StepTimeout) * time.Second
+	case b.Project.StepTimeout != 0:
+		return time.Duration(b.Project.StepTimeout) * time.Second
+	default:
+		return DEFAULT_STEP_TIMEOUT * time.Second
+	}
+}
+
+// env returns the variables that are exposed to every step of the build
+func (b Build) env() []string {
+	env := []string{
+		"GHHOOKS_BUILD_ID=" + strconv.FormatInt(b.ID, 10),
+		"GHHOOKS_PROJECT=" + b.ProjectName,
+		"GHHOOKS_PIPELINE=" + b.PipelineName,
+		"GHHOOKS_EVENT=" + b.Trigger.Event,
+		"GHHOOKS_REF=" + b.Trigger.Ref,
+		"GHHOOKS_SHA=" + b.Trigger.Commit,
+	}
+	if b.Trigger.Tag != "" {
+		env = append(env, "GHHOOKS_TAG="+b.Trigger.Tag)
+	}
+	if b.Trigger.PR != 0 {
+		env = append(env,
+			"GHHOOKS_PR_NUMBER="+strconv.Itoa(b.Trigger.PR),
+			"GHHOOKS_PR_BASE="+b.Trigger.Base,
+		)
+	}
+	// configured env comes last so that it wins over the environment ghhooks runs in
+	for _, name := range sortedKeys(b.Project.Env) {
+		env = append(env, name+"="+b.Project.Env[name])
+	}
+	return env
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// saveState stores the state of build, pull request builds are kept apart from branch builds
+// so that a failing pull request never masks the deploy status
+func (b Build) saveState(state JobState) {
+	ResultMap.Mu.Lock()
+	if b.Trigger.PR != 0 {
+		if ResultMap.PRs[b.ProjectName] == nil {
+			ResultMap.PRs[b.ProjectName] = make(map[int]JobState)
+		}
+		ResultMap.PRs[b.ProjectName][b.Trigger.PR] = state
+	} else {
+		if ResultMap.Map[b.ProjectName] == nil {
+			ResultMap.Map[b.ProjectName] = make(map[string]JobState)
+		}
+		ResultMap.Map[b.ProjectName][b.PipelineName] = state
+	}
+	ResultMap.Mu.Unlock()
+	History.Update(state)
+
+	// updating the live status, only branch builds are shown on status page
+	if feed, ok := PipelineFeed(b.ProjectName, b.PipelineName); ok && b.Trigger.PR == 0 {
+		feed.Publish(b.ProjectName, state)
+	}
+	DashboardFeed.Publish(b.ProjectName, state)
+}
+
+func Job(args ...any) error {
+	build := args[0].(Build)
+
+	state := JobState{
+		ID:             build.ID,
+		LastBuildStart: time.Now().UTC(),
+		StepResults:    make([]Result, 0),
+		BuildStatus:    PENDING,
+		Pipeline:       build.PipelineName,
+		Steps:          build.Pipeline.Steps,
+		Trigger:        build.Trigger,
+	}
+	// forgetting live updates of previous build whenever a new build starts
+	if feed, ok := PipelineFeed(build.ProjectName, build.PipelineName); ok && build.Trigger.PR == 0 {
+		feed.Reset()
+	}
+	build.saveState(state)
+
+	env := append(os.Environ(), build.env()...)
+	ok := build.runSteps(&state, build.Pipeline.Steps, env, build.stepTimeout(), true)
+
+	// pipeline file is read once configured steps have checked out the built commit
+	if ok && build.Project.PipelineFile != "" {
+		rendered, err := build.loadRepoPipeline()
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err.Error())
+			// steps of state are the configured ones until copied, config is shared between builds
+			state.Steps = append(append([][]string{}, state.Steps...), []string{build.Project.PipelineFile})
+			state.StepResults = append(state.StepResults, Result{
+				Error:       err,
+				Description: err.Error(),
+			})
+			ok = false
+		case rendered != nil:
+			state.Rendered = rendered
+			state.Steps = append(append([][]string{}, state.Steps...), rendered.Steps...)
+			build.saveState(state)
+
+			repoEnv := env
+			for _, name := range sortedKeys(rendered.Env) {
+				repoEnv = append(repoEnv, name+"="+rendered.Env[name])
+			}
+			timeout := time.Duration(rendered.StepTimeout) * time.Second
+			// never through shell, allowedCommands could be sidestepped otherwise
+			ok = build.runSteps(&state, rendered.Steps, repoEnv, timeout, false)
+		}
+	}
+
+	state.BuildStatus = SUCCESS
+	if !ok {
+		state.BuildStatus = FAILED
+	}
+	state.FinishedAt = time.Now().UTC()
+	build.saveState(state)
+	observeBuild(build, state)
+	return nil
+}
+
+// dropBuilds - fails builds that were taken out of a drained queue before they ran, so that they do not
+// stay queued and their waiters are released
+func dropBuilds(jobs []jobqueue.Job, reason string) {
+	for _, job := range jobs {
+		build, ok := job.Args[0].(Build)
+		if !ok {
+			continue
+		}
+		now := time.Now().UTC()
+		// reported on the first step, the way a build that failed right away looks
+		state := JobState{
+			ID:             build.ID,
+			LastBuildStart: now,
+			FinishedAt:     now,
+			StepResults: []Result{{
+				Error:       errors.New(reason),
+				Description: reason,
+			}},
+			BuildStatus: FAILED,
+			Pipeline:    build.PipelineName,
+			Steps:       build.Pipeline.Steps,
+			Trigger:     build.Trigger,
+		}
+		build.saveState(state)
+	}
+}
+
+// runSteps - runs steps one after another recording their results in state, stops at the first
+// failing step and reports if every step succeeded
+func (b Build) runSteps(state *JobState, steps [][]string, env []string, timeout time.Duration, shell bool) bool {
+	for _, step := range steps {
+
+		// empty steps are reported too, so that step results line up with steps
+		if len(step) == 0 {
+			fmt.Fprintln(os.Stderr, "empty step")
+			state.StepResults = append(state.StepResults, Result{
+				Description: "empty step, skipped",
+			})
+			b.saveState(*state)
+			continue
+		}
+
+		command, args := step[0], step[1:]
+		if shell {
+			command, args = b.Project.command(step)
+		}
+
+		//DONE: create context with deadline from global context
+		ctx, cancel := context.WithTimeout(Ctx, timeout)
+		cmd := exec.CommandContext(ctx, command, args...)
+		cmd.Dir = b.Project.Cwd
+		cmd.Env = env
+		setProcAttr(cmd)
+		stepStart := time.Now()
+		out, err := cmd.Output()
+		cancel()
+
+		//reporting results
+		description := "step ran successfully"
+		if err != nil {
+			description = err.Error()
+		}
+		state.StepResults = append(state.StepResults, Result{
+			Error:       err,
+			Output:      string(out),
+			Description: description,
+			Duration:    time.Since(stepStart).Seconds(),
+		})
+		stepDuration.Observe(time.Since(stepStart).Seconds(), b.ProjectName, b.PipelineName)
+		b.saveState(*state)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package core
+
+import "sync"
+
+// updates kept for resuming subscribers, older ones are dropped
+const LIVE_FEED_BACKLOG = 500
+
+// LiveEvent is a state update of a build, ids keep increasing across builds
+type LiveEvent struct {
+	ID      int64
+	Project string
+	State   JobState
+}
+
+// LiveFeed fans out state updates of a project pipeline (or of every project for the dashboard) to every
+// subscriber, updates of the current build are kept so that subscribers can resume from the last event they have seen
+type LiveFeed struct {
+	mu     sync.Mutex
+	lastID int64
+	events []LiveEvent
+	subs   map[chan LiveEvent]struct{}
+}
+
+func NewLiveFeed() *LiveFeed {
+	return &LiveFeed{
+		events: make([]LiveEvent, 0),
+		subs:   make(map[chan LiveEvent]struct{}),
+	}
+}
+
+// Reset - forgets updates of previous build, called whenever a new build starts on a pipeline feed
+func (f *LiveFeed) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = make([]LiveEvent, 0)
+}
+
+// Publish - sends state to every subscriber, a subscriber that is too slow misses the update,
+// since every state carries all step results so far the next update catches it up
+func (f *LiveFeed) Publish(project string, state JobState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastID++
+	event := LiveEvent{ID: f.lastID, Project: project, State: state}
+	f.events = append(f.events, event)
+	if len(f.events) > LIVE_FEED_BACKLOG {
+		f.events = f.events[len(f.events)-LIVE_FEED_BACKLOG:]
+	}
+	for sub := range f.subs {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe - returns channel of updates along with updates of current build that came after lastEventID,
+// backlog is empty when lastEventID is 0. cancel has to be called once subscriber is done
+func (f *LiveFeed) Subscribe(lastEventID int64) (<-chan LiveEvent, []LiveEvent, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub := make(chan LiveEvent, 16)
+	f.subs[sub] = struct{}{}
+
+	backlog := make([]LiveEvent, 0)
+	if lastEventID > 0 {
+		for _, event := range f.events {
+			if event.ID > lastEventID {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	cancel := func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subs, sub)
+	}
+	return sub, backlog, cancel
+}
+
+// Subscribers - number of open subscriptions
+func (f *LiveFeed) Subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
@@ -0,0 +1,33 @@
+package core
+
+import "ghhooks.com/hook/metrics"
+
+var (
+	buildsTotal = metrics.NewCounterVec("ghhooks_builds_total",
+		"Finished builds by project, pipeline and status.", "project", "pipeline", "status")
+	buildDuration = metrics.NewHistogramVec("ghhooks_build_duration_seconds",
+		"Duration of finished builds.", metrics.DurationBuckets, "project", "pipeline")
+	stepDuration = metrics.NewHistogramVec("ghhooks_step_duration_seconds",
+		"Duration of build steps.", metrics.DurationBuckets, "project", "pipeline")
+	_ = metrics.NewGaugeFunc("ghhooks_queue_depth",
+		"Builds waiting in project queue.", []string{"project"}, queueDepth)
+)
+
+func queueDepth() []metrics.Sample {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	samples := make([]metrics.Sample, 0, len(Queues))
+	for name, jq := range Queues {
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{name},
+			Value:       float64(jq.Len()),
+		})
+	}
+	return samples
+}
+
+// observeBuild - records metrics of a finished build
+func observeBuild(build Build, state JobState) {
+	buildsTotal.Inc(build.ProjectName, build.PipelineName, state.BuildStatus)
+	buildDuration.Observe(state.FinishedAt.Sub(state.LastBuildStart).Seconds(), build.ProjectName, build.PipelineName)
+}
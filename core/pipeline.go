@@ -0,0 +1,156 @@
+package core
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// events a pipeline can be triggered on
+const (
+	EVENT_PUSH         = "push"
+	EVENT_DELETE       = "delete"
+	EVENT_RELEASE      = "release"
+	EVENT_PULL_REQUEST = "pull_request"
+	EVENT_SCHEDULE     = "schedule"
+	// builds triggered by hand run like scheduled ones, whatever events pipeline is on
+	EVENT_MANUAL = "manual"
+)
+
+// name of the pipeline that is built from project steps when project has no pipelines configured
+const DEFAULT_PIPELINE = "default"
+
+// Pipeline is a named list of steps along with the conditions it is triggered on
+type Pipeline struct {
+	// events pipeline runs on, one of push, delete, release, pull_request
+	On []string `toml:"on" json:"on"`
+	// path.Match patterns of pushed/deleted branches or of pull request base branches,
+	// defaults to project branch for pipelines that run on push or delete
+	Branches []string `toml:"branches" json:"branches"`
+	// path.Match patterns of release tags, all tags when empty
+	Tags []string `toml:"tags" json:"tags"`
+	// pipeline also runs when schedule is due, @every <duration>, @hourly, @daily, @weekly or HH:MM (UTC)
+	Schedule    string     `toml:"schedule" json:"schedule"`
+	Steps       [][]string `toml:"steps" json:"steps"`
+	StepTimeout int        `toml:"stepTimeout" json:"stepTimeout"`
+}
+
+func (pl Pipeline) RunsOn(event string) bool {
+	for _, e := range pl.On {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches - reports if pipeline should run for given trigger
+func (pl Pipeline) Matches(t Trigger) bool {
+	event := t.Event
+	if t.Deleted {
+		event = EVENT_DELETE
+	}
+	if !pl.RunsOn(event) {
+		return false
+	}
+	switch event {
+	case EVENT_PUSH, EVENT_DELETE:
+		return matchAny(pl.Branches, t.Branch())
+	case EVENT_PULL_REQUEST:
+		return len(pl.Branches) == 0 || matchAny(pl.Branches, t.Base)
+	case EVENT_RELEASE:
+		return len(pl.Tags) == 0 || matchAny(pl.Tags, t.Tag)
+	default:
+		return true
+	}
+}
+
+// Pipelines - returns configured pipelines of project, when there are none they are made from
+// steps (push and release on branch), prSteps (pull requests) and teardownSteps (branch deletion)
+func (p Project) Pipelines() map[string]Pipeline {
+	pipelines := make(map[string]Pipeline)
+	if len(p.Pipeline) > 0 {
+		for name, pl := range p.Pipeline {
+			if len(pl.Branches) == 0 && p.Branch != "" && (pl.RunsOn(EVENT_PUSH) || pl.RunsOn(EVENT_DELETE)) {
+				pl.Branches = []string{p.Branch}
+			}
+			pipelines[name] = pl
+		}
+		return pipelines
+	}
+
+	pipelines[DEFAULT_PIPELINE] = Pipeline{
+		On:       []string{EVENT_PUSH, EVENT_RELEASE},
+		Branches: []string{p.Branch},
+		Tags:     p.ReleaseTags,
+		Steps:    p.Steps,
+	}
+	if len(p.PRSteps) > 0 {
+		pipelines["pr"] = Pipeline{
+			On:       []string{EVENT_PULL_REQUEST},
+			Branches: p.PRBranches,
+			Steps:    p.PRSteps,
+		}
+	}
+	if len(p.TeardownSteps) > 0 {
+		pipelines["teardown"] = Pipeline{
+			On:       []string{EVENT_DELETE},
+			Branches: []string{p.Branch},
+			Steps:    p.TeardownSteps,
+		}
+	}
+	return pipelines
+}
+
+// PipelineNames - returns sorted names of project pipelines
+func (p Project) PipelineNames() []string {
+	names := make([]string, 0)
+	for name := range p.Pipelines() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultPipeline - pipeline shown when none is asked for, default if it exists otherwise first by name
+func (p Project) DefaultPipeline() string {
+	names := p.PipelineNames()
+	for _, name := range names {
+		if name == DEFAULT_PIPELINE {
+			return name
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// Route - returns sorted names of pipelines that should run for given trigger
+func (p Project) Route(t Trigger) []string {
+	pipelines := p.Pipelines()
+	names := make([]string, 0)
+	for _, name := range p.PipelineNames() {
+		if pipelines[name].Matches(t) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Branch - returns branch name of ref, empty for refs that are not branches
+func (t Trigger) Branch() string {
+	if !strings.HasPrefix(t.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(t.Ref, "refs/heads/")
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipelineMatches(t *testing.T) {
+	deploy := Pipeline{On: []string{EVENT_PUSH, EVENT_DELETE}, Branches: []string{"main", "release/*"}}
+	pr := Pipeline{On: []string{EVENT_PULL_REQUEST}}
+	prMain := Pipeline{On: []string{EVENT_PULL_REQUEST}, Branches: []string{"main"}}
+	publish := Pipeline{On: []string{EVENT_RELEASE}, Tags: []string{"v*"}}
+	anyRelease := Pipeline{On: []string{EVENT_RELEASE}}
+
+	tests := []struct {
+		name     string
+		pipeline Pipeline
+		trigger  Trigger
+		want     bool
+	}{
+		{"push to branch", deploy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main"}, true},
+		{"push to matching branch", deploy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/release/1.2"}, true},
+		{"push to other branch", deploy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/dev"}, false},
+		{"push of tag", deploy, Trigger{Event: EVENT_PUSH, Ref: "refs/tags/main"}, false},
+		{"branch deleted", deploy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main", Deleted: true}, true},
+		{"delete not configured", Pipeline{On: []string{EVENT_PUSH}, Branches: []string{"main"}}, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main", Deleted: true}, false},
+		{"event not configured", deploy, Trigger{Event: EVENT_RELEASE, Tag: "v1"}, false},
+		{"pull request against any base", pr, Trigger{Event: EVENT_PULL_REQUEST, Base: "dev"}, true},
+		{"pull request against base", prMain, Trigger{Event: EVENT_PULL_REQUEST, Base: "main"}, true},
+		{"pull request against other base", prMain, Trigger{Event: EVENT_PULL_REQUEST, Base: "dev"}, false},
+		{"release tag", publish, Trigger{Event: EVENT_RELEASE, Tag: "v1.0.0"}, true},
+		{"release other tag", publish, Trigger{Event: EVENT_RELEASE, Tag: "nightly"}, false},
+		{"release any tag", anyRelease, Trigger{Event: EVENT_RELEASE, Tag: "nightly"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pipeline.Matches(tt.trigger); got != tt.want {
+				t.Errorf("Matches(%+v) = %v, want %v", tt.trigger, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectRoute(t *testing.T) {
+	legacy := Project{
+		Branch:        "main",
+		Steps:         [][]string{{"make"}},
+		PRSteps:       [][]string{{"make", "test"}},
+		TeardownSteps: [][]string{{"make", "clean"}},
+		ReleaseTags:   []string{"v*"},
+	}
+	named := Project{
+		Branch: "main",
+		Pipeline: map[string]Pipeline{
+			"deploy":  {On: []string{EVENT_PUSH}},
+			"staging": {On: []string{EVENT_PUSH}, Branches: []string{"staging"}},
+			"test":    {On: []string{EVENT_PUSH, EVENT_PULL_REQUEST}, Branches: []string{"*"}},
+			"nightly": {On: []string{EVENT_SCHEDULE}, Schedule: "@daily"},
+		},
+	}
+	tests := []struct {
+		name    string
+		project Project
+		trigger Trigger
+		want    []string
+	}{
+		{"legacy push", legacy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main"}, []string{DEFAULT_PIPELINE}},
+		{"legacy release", legacy, Trigger{Event: EVENT_RELEASE, Tag: "v2"}, []string{DEFAULT_PIPELINE}},
+		{"legacy pull request", legacy, Trigger{Event: EVENT_PULL_REQUEST, Base: "main"}, []string{"pr"}},
+		{"legacy delete", legacy, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main", Deleted: true}, []string{"teardown"}},
+		{"named push defaults to project branch", named, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main"}, []string{"deploy", "test"}},
+		{"named push to other branch", named, Trigger{Event: EVENT_PUSH, Ref: "refs/heads/staging"}, []string{"staging", "test"}},
+		{"named pull request", named, Trigger{Event: EVENT_PULL_REQUEST, Base: "dev"}, []string{"test"}},
+		{"nothing routed", named, Trigger{Event: EVENT_RELEASE, Tag: "v1"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.Route(tt.trigger); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Route(%+v) = %v, want %v", tt.trigger, got, tt.want)
+			}
+		})
+	}
+
+	if got := legacy.DefaultPipeline(); got != DEFAULT_PIPELINE {
+		t.Errorf("DefaultPipeline() of legacy project = %s, want %s", got, DEFAULT_PIPELINE)
+	}
+	if got := named.DefaultPipeline(); got != "deploy" {
+		t.Errorf("DefaultPipeline() of named pipelines = %s, want deploy", got)
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// proxies trusted when trustedProxies is not configured, the ones running on the same host
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// ParseNetworks - parses CIDR ranges, plain addresses are taken as single address ranges
+func ParseNetworks(addrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", addr)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address range %q", addr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ContainsIP - reports if ip is in any of the networks
+func ContainsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBasePath - base path starts with a slash and has no trailing slash, empty when served at root
+func normalizeBasePath(basePath string) (string, error) {
+	basePath = strings.TrimSuffix(basePath, "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		return "", fmt.Errorf("basePath %q should start with /", basePath)
+	}
+	return basePath, nil
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"ghhooks.com/hook/jobqueue"
+)
+
+// config is swapped as a whole on reload, a Doc returned by Conf never changes afterwards.
+// builds keep the project and pipeline they were created with
+
+var serverConf atomic.Value
+
+var (
+	configLocation string
+	logger         *log.Logger
+	workers        *sync.WaitGroup
+
+	// guards Queues, LiveFeeds and retiredQueues
+	registryMu sync.RWMutex
+	reloadMu   sync.Mutex
+
+	// drained queues of removed projects whose worker may still be running a build, a project added
+	// back starts its worker only once the old one exited so that builds never share cwd
+	retiredQueues = make(map[string]*jobqueue.JobQueue)
+)
+
+// Conf - config currently in use
+func Conf() Doc {
+	conf, _ := serverConf.Load().(Doc)
+	return conf
+}
+
+type ReloadResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Resized []string `json:"resized"`
+}
+
+// ProjectQueue - build queue of project
+func ProjectQueue(projectName string) (*jobqueue.JobQueue, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	jq, ok := Queues[projectName]
+	return jq, ok
+}
+
+// PipelineFeed - live feed of project pipeline, missing once the pipeline is removed by a reload
+func PipelineFeed(projectName string, pipeline string) (*LiveFeed, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	feed, ok := LiveFeeds[projectName][pipeline]
+	return feed, ok
+}
+
+// DrainAll - drains queue of every project, running builds are let finish and queued ones fail
+func DrainAll() {
+	registryMu.RLock()
+	dropped := Queues.DrainAll()
+	registryMu.RUnlock()
+	dropBuilds(dropped, "build was not run, server shut down")
+}
+
+// applyConfig - swaps config in, queues and live feeds are created for added projects and pipelines
+// and dropped for removed ones, queued builds of removed projects fail. queues whose queueSize changed
+// are replaced, their queued builds move over as long as they fit
+func applyConfig(conf Doc) ReloadResult {
+	res := ReloadResult{
+		Added:   make([]string, 0),
+		Removed: make([]string, 0),
+		Resized: make([]string, 0),
+	}
+	registryMu.Lock()
+	dropped := make([]jobqueue.Job, 0)
+	for projectName, jq := range Queues {
+		if _, ok := conf.Project[projectName]; ok {
+			continue
+		}
+		dropped = append(dropped, jq.Drain()...)
+		delete(Queues, projectName)
+		delete(LiveFeeds, projectName)
+		retireQueue(projectName, jq)
+		res.Removed = append(res.Removed, projectName)
+	}
+
+	overflow := make([]jobqueue.Job, 0)
+	for projectName, project := range conf.Project {
+		if old, ok := Queues[projectName]; ok && old.Cap() != project.queueSize() {
+			queued := old.Drain()
+			delete(Queues, projectName)
+			jq := jobqueue.NewJobQueue(projectName, make(chan jobqueue.Job, project.queueSize()), 1, logger, workers)
+			Queues.Register(jq)
+			// running build of old queue finishes first so that builds never share cwd
+			jq.StartWorkersAfter(old.Done())
+			for _, job := range queued {
+				if !jq.Enqueue(job) {
+					overflow = append(overflow, job)
+				}
+			}
+			res.Resized = append(res.Resized, projectName)
+		}
+		if _, ok := Queues[projectName]; !ok {
+			jq := jobqueue.NewJobQueue(projectName, make(chan jobqueue.Job, project.queueSize()), 1, logger, workers)
+			Queues.Register(jq)
+			if old, ok := retiredQueues[projectName]; ok {
+				delete(retiredQueues, projectName)
+				jq.StartWorkersAfter(old.Done())
+			} else {
+				jq.StartWorkers()
+			}
+			res.Added = append(res.Added, projectName)
+		}
+		feeds := make(map[string]*LiveFeed)
+		for name := range project.Pipelines() {
+			if feed, ok := LiveFeeds[projectName][name]; ok {
+				feeds[name] = feed
+			} else {
+				feeds[name] = NewLiveFeed()
+			}
+		}
+		LiveFeeds[projectName] = feeds
+	}
+
+	serverConf.Store(conf)
+	registryMu.Unlock()
+
+	// saving state looks feeds up, so only once registry is unlocked
+	dropBuilds(dropped, "build was not run, project was removed from config")
+	dropBuilds(overflow, "build was not run, queueSize was reduced")
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Resized)
+	return res
+}
+
+// retireQueue - keeps drained queue of removed project until its worker exited
+func retireQueue(projectName string, jq *jobqueue.JobQueue) {
+	retiredQueues[projectName] = jq
+	go func() {
+		<-jq.Done()
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		if retiredQueues[projectName] == jq {
+			delete(retiredQueues, projectName)
+		}
+	}()
+}
+
+// Reload - loads config file again and swaps it in when it is valid, readiness reports the error otherwise.
+// basePath is fixed for the lifetime of the process since routes are mounted on it
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	conf, err := loadCheckedConfig(configLocation, logger)
+	if err == nil && conf.BasePath != Conf().BasePath {
+		err = fmt.Errorf("basePath can not change without a restart")
+	}
+	if err != nil {
+		SetConfigError(err)
+		return ReloadResult{}, err
+	}
+
+	StopSchedules()
+	res := applyConfig(conf)
+	StartSchedules(logger)
+	SetConfigError(nil)
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitStatus(t *testing.T, id int64, status string) JobState {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, state, ok := History.Get(id); ok && state.BuildStatus == status {
+			return state
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("build %d did not reach %s", id, status)
+	return JobState{}
+}
+
+func TestApplyConfigRemovedProject(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	config := "[project.p]\nbranch = \"main\"\nsecret = \"x\"\ncwd = '" + dir + "'\nsteps = [[\"sleep\", \"0.5\"]]\n"
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := ServerInit(file, log.New(io.Discard, "", 0), &wg); err != nil {
+		t.Fatal(err)
+	}
+	conf := Conf()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trigger := Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main"}
+	running := NewBuild("p", conf.Project["p"], DEFAULT_PIPELINE, trigger)
+	queued := NewBuild("p", conf.Project["p"], DEFAULT_PIPELINE, trigger)
+	if !Enqueue(running) {
+		t.Fatal("could not enqueue build")
+	}
+	waitStatus(t, running.ID, PENDING)
+	if !Enqueue(queued) {
+		t.Fatal("could not enqueue build")
+	}
+
+	// removing the project fails the queued build and releases its waiters
+	applyConfig(Doc{Project: map[string]Project{}})
+	state, err := History.Wait(ctx, queued.ID)
+	if err != nil || state.BuildStatus != FAILED {
+		t.Fatalf("queued build of removed project = %s, %v, want failed", state.BuildStatus, err)
+	}
+
+	// added back, builds wait for the worker of the removed project
+	applyConfig(conf)
+	next := NewBuild("p", conf.Project["p"], DEFAULT_PIPELINE, trigger)
+	if !Enqueue(next) {
+		t.Fatal("could not enqueue build")
+	}
+	if _, state, _ := History.Get(next.ID); state.BuildStatus != QUEUED {
+		t.Fatalf("build of re-added project is %s while old worker runs, want queued", state.BuildStatus)
+	}
+	first, err := History.Wait(ctx, running.ID)
+	if err != nil || first.BuildStatus != SUCCESS {
+		t.Fatalf("running build = %s, %v, want success", first.BuildStatus, err)
+	}
+	second, err := History.Wait(ctx, next.ID)
+	if err != nil || second.BuildStatus != SUCCESS {
+		t.Fatalf("build of re-added project = %s, %v, want success", second.BuildStatus, err)
+	}
+	if second.LastBuildStart.Before(first.FinishedAt) {
+		t.Errorf("build of re-added project started at %v before old build finished at %v", second.LastBuildStart, first.FinishedAt)
+	}
+
+	DrainAll()
+	wg.Wait()
+}
+
+func TestApplyConfigQueueSize(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	config := "[project.p]\nbranch = \"main\"\nsecret = \"x\"\ncwd = '" + dir + "'\nqueueSize = 2\nsteps = [[\"sleep\", \"0.3\"]]\n"
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := ServerInit(file, log.New(io.Discard, "", 0), &wg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DrainAll()
+		wg.Wait()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	withQueueSize := func(size int) Doc {
+		conf := Conf()
+		projects := map[string]Project{}
+		for name, project := range conf.Project {
+			project.QueueSize = size
+			projects[name] = project
+		}
+		conf.Project = projects
+		return conf
+	}
+	trigger := Trigger{Event: EVENT_PUSH, Ref: "refs/heads/main"}
+	enqueue := func() Build {
+		t.Helper()
+		build := NewBuild("p", Conf().Project["p"], DEFAULT_PIPELINE, trigger)
+		if !Enqueue(build) {
+			t.Fatal("could not enqueue build")
+		}
+		return build
+	}
+
+	// unchanged queueSize keeps the queue
+	if res := applyConfig(withQueueSize(2)); len(res.Resized) != 0 {
+		t.Fatalf("resized = %v, want none", res.Resized)
+	}
+
+	running := enqueue()
+	waitStatus(t, running.ID, PENDING)
+	first, second := enqueue(), enqueue()
+
+	// shrinking moves queued builds that fit and fails the rest
+	res := applyConfig(withQueueSize(1))
+	if len(res.Resized) != 1 || res.Resized[0] != "p" {
+		t.Fatalf("resized = %v, want [p]", res.Resized)
+	}
+	if jq, _ := ProjectQueue("p"); jq.Cap() != 1 {
+		t.Fatalf("queue capacity = %d, want 1", jq.Cap())
+	}
+	if state, err := History.Wait(ctx, second.ID); err != nil || state.BuildStatus != FAILED {
+		t.Fatalf("build over new queueSize = %s, %v, want failed", state.BuildStatus, err)
+	}
+
+	// growing keeps every queued build
+	applyConfig(withQueueSize(3))
+	if jq, _ := ProjectQueue("p"); jq.Cap() != 3 {
+		t.Fatalf("queue capacity = %d, want 3", jq.Cap())
+	}
+	third := enqueue()
+
+	done, err := History.Wait(ctx, running.ID)
+	if err != nil || done.BuildStatus != SUCCESS {
+		t.Fatalf("running build = %s, %v, want success", done.BuildStatus, err)
+	}
+	for _, build := range []Build{first, third} {
+		state, err := History.Wait(ctx, build.ID)
+		if err != nil || state.BuildStatus != SUCCESS {
+			t.Fatalf("queued build %d = %s, %v, want success", build.ID, state.BuildStatus, err)
+		}
+		if state.LastBuildStart.Before(done.FinishedAt) {
+			t.Errorf("build %d started at %v before running build finished at %v", build.ID, state.LastBuildStart, done.FinishedAt)
+		}
+	}
+}
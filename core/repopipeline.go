@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RepoDoc is the pipeline file projects with pipelineFile keep in their repository, steps of
+// [pipeline.<name>] are run after the steps of pipeline <name> configured on the server
+type RepoDoc struct {
+	Pipeline map[string]RepoPipeline `toml:"pipeline"`
+}
+
+type RepoPipeline struct {
+	Steps       [][]string        `toml:"steps" json:"steps"`
+	StepTimeout int               `toml:"stepTimeout" json:"stepTimeout,omitempty"`
+	Env         map[string]string `toml:"env" json:"env,omitempty"`
+}
+
+// RenderedPipeline - repository steps of a build after server restrictions were applied, kept in build result
+type RenderedPipeline struct {
+	File string `json:"file"`
+	RepoPipeline
+}
+
+// loadRepoPipeline - reads pipeline of build from pipeline file in project cwd, which server steps are
+// expected to have checked out at the built commit. nil when file has no such pipeline
+func (b Build) loadRepoPipeline() (*RenderedPipeline, error) {
+	file := filepath.Join(b.Project.Cwd, b.Project.PipelineFile)
+	var doc RepoDoc
+	md, err := toml.DecodeFile(file, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline file: %v", err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("pipeline file: unknown key %s", undecoded[0])
+	}
+	pipeline, ok := doc.Pipeline[b.PipelineName]
+	if !ok {
+		return nil, nil
+	}
+	if err := b.Project.restrict(&pipeline); err != nil {
+		return nil, fmt.Errorf("pipeline file: %v", err)
+	}
+	return &RenderedPipeline{File: b.Project.PipelineFile, RepoPipeline: pipeline}, nil
+}
+
+// restrict - server config stays in charge of what repository pipelines may do, commands have to match
+// allowedCommands (none when empty, ["*"] allows any), env names allowedEnv (no env when empty) and step
+// timeouts are capped at maxStepTimeout, or at the step timeout of the project when it is not set
+func (p Project) restrict(pipeline *RepoPipeline) error {
+	for i, step := range pipeline.Steps {
+		if len(step) == 0 {
+			return fmt.Errorf("steps[%d] is empty", i)
+		}
+		if !allowed(p.AllowedCommands, step[0]) {
+			return fmt.Errorf("steps[%d]: command %s is not allowed", i, step[0])
+		}
+	}
+	names := make([]string, 0, len(pipeline.Env))
+	for name := range pipeline.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !allowed(p.AllowedEnv, name) {
+			return fmt.Errorf("env %s is not allowed", name)
+		}
+	}
+	if pipeline.StepTimeout < 0 {
+		return fmt.Errorf("stepTimeout should not be negative")
+	}
+	if limit := p.maxStepTimeout(); pipeline.StepTimeout == 0 || pipeline.StepTimeout > limit {
+		pipeline.StepTimeout = limit
+	}
+	return nil
+}
+
+// maxStepTimeout - longest step timeout in seconds a pipeline file may ask for
+func (p Project) maxStepTimeout() int {
+	switch {
+	case p.MaxStepTimeout > 0:
+		return p.MaxStepTimeout
+	case p.StepTimeout > 0:
+		return p.StepTimeout
+	default:
+		return DEFAULT_STEP_TIMEOUT
+	}
+}
+
+// allowed - like matchAny, but "*" also allows commands with a path
+func allowed(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return matchAny(patterns, name)
+}
+
+// checkRepoPolicy - reports invalid patterns of pipeline file restrictions
+func checkRepoPolicy(add func(string, string, ...any), key string, project Project) {
+	if project.PipelineFile == "" {
+		if len(project.AllowedCommands) > 0 || len(project.AllowedEnv) > 0 || project.MaxStepTimeout != 0 {
+			add(key+".pipelineFile", "allowedCommands, allowedEnv and maxStepTimeout are only used with pipelineFile")
+		}
+		return
+	}
+	if filepath.IsAbs(project.PipelineFile) {
+		add(key+".pipelineFile", "should be a path relative to cwd")
+	}
+	for _, pattern := range project.AllowedCommands {
+		checkPattern(add, key+".allowedCommands", pattern)
+	}
+	for _, pattern := range project.AllowedEnv {
+		checkPattern(add, key+".allowedEnv", pattern)
+	}
+	if len(project.AllowedCommands) == 0 {
+		add(key+".allowedCommands", `not set, every step of pipeline file is rejected, use ["*"] to allow any command`)
+	}
+	if project.MaxStepTimeout < 0 {
+		add(key+".maxStepTimeout", "should not be negative")
+	}
+}
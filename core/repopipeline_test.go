@@ -0,0 +1,101 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestrict(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     Project
+		pipeline    RepoPipeline
+		err         string
+		stepTimeout int
+	}{
+		{
+			name:     "no allowed commands rejects every step",
+			project:  Project{},
+			pipeline: RepoPipeline{Steps: [][]string{{"make"}}},
+			err:      "command make is not allowed",
+		},
+		{
+			name:        "star allows any command",
+			project:     Project{AllowedCommands: []string{"*"}},
+			pipeline:    RepoPipeline{Steps: [][]string{{"/usr/bin/make", "test"}}},
+			stepTimeout: DEFAULT_STEP_TIMEOUT,
+		},
+		{
+			name:     "command outside patterns",
+			project:  Project{AllowedCommands: []string{"make", "./scripts/*"}},
+			pipeline: RepoPipeline{Steps: [][]string{{"./scripts/test.sh"}, {"curl"}}},
+			err:      "steps[1]: command curl is not allowed",
+		},
+		{
+			name:     "empty step",
+			project:  Project{AllowedCommands: []string{"*"}},
+			pipeline: RepoPipeline{Steps: [][]string{{}}},
+			err:      "steps[0] is empty",
+		},
+		{
+			name:     "env is not allowed by default",
+			project:  Project{AllowedCommands: []string{"*"}},
+			pipeline: RepoPipeline{Env: map[string]string{"NODE_ENV": "test"}},
+			err:      "env NODE_ENV is not allowed",
+		},
+		{
+			name:        "env matching patterns",
+			project:     Project{AllowedCommands: []string{"*"}, AllowedEnv: []string{"NODE_*"}},
+			pipeline:    RepoPipeline{Env: map[string]string{"NODE_ENV": "test"}},
+			stepTimeout: DEFAULT_STEP_TIMEOUT,
+		},
+		{
+			name:     "negative step timeout",
+			project:  Project{AllowedCommands: []string{"*"}},
+			pipeline: RepoPipeline{StepTimeout: -1},
+			err:      "stepTimeout should not be negative",
+		},
+		{
+			name:        "capped at maxStepTimeout",
+			project:     Project{AllowedCommands: []string{"*"}, StepTimeout: 60, MaxStepTimeout: 900},
+			pipeline:    RepoPipeline{StepTimeout: 3600},
+			stepTimeout: 900,
+		},
+		{
+			name:        "shorter timeout is kept",
+			project:     Project{AllowedCommands: []string{"*"}, MaxStepTimeout: 900},
+			pipeline:    RepoPipeline{StepTimeout: 30},
+			stepTimeout: 30,
+		},
+		{
+			name:        "capped at project stepTimeout without maxStepTimeout",
+			project:     Project{AllowedCommands: []string{"*"}, StepTimeout: 60},
+			pipeline:    RepoPipeline{StepTimeout: 3600},
+			stepTimeout: 60,
+		},
+		{
+			name:        "capped at default stepTimeout",
+			project:     Project{AllowedCommands: []string{"*"}},
+			pipeline:    RepoPipeline{StepTimeout: 3600},
+			stepTimeout: DEFAULT_STEP_TIMEOUT,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := tt.pipeline
+			err := tt.project.restrict(&pipeline)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("restrict() = %v, want error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restrict() = %v", err)
+			}
+			if pipeline.StepTimeout != tt.stepTimeout {
+				t.Errorf("stepTimeout = %d, want %d", pipeline.StepTimeout, tt.stepTimeout)
+			}
+		})
+	}
+}
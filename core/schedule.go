@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var stopSchedules chan struct{}
+var schedulesWg sync.WaitGroup
+
+// NextRun - returns time after from at which schedule is due, schedule is one of
+// @every <duration>, @hourly, @daily (or @midnight), @weekly or HH:MM, all in UTC
+func NextRun(schedule string, from time.Time) (time.Time, error) {
+	from = from.UTC()
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch {
+	case strings.HasPrefix(schedule, "@every "):
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(schedule, "@every ")))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid schedule %q: %v", schedule, err)
+		}
+		if interval < time.Minute {
+			return time.Time{}, fmt.Errorf("invalid schedule %q: interval should be at least a minute", schedule)
+		}
+		return from.Add(interval), nil
+	case schedule == "@hourly":
+		return from.Truncate(time.Hour).Add(time.Hour), nil
+	case schedule == "@daily" || schedule == "@midnight":
+		return day.AddDate(0, 0, 1), nil
+	case schedule == "@weekly":
+		return day.AddDate(0, 0, 7-int(day.Weekday())), nil
+	}
+
+	t, err := time.Parse("15:04", schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule %q: expected @every <duration>, @hourly, @daily, @weekly or HH:MM", schedule)
+	}
+	next := day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	if !next.After(from) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// StartSchedules - enqueues builds of every pipeline that has a schedule whenever it is due
+func StartSchedules(l *log.Logger) {
+	stopSchedules = make(chan struct{})
+	for projectName, project := range Conf().Project {
+		for name, pipeline := range project.Pipelines() {
+			if pipeline.Schedule == "" {
+				continue
+			}
+			schedulesWg.Add(1)
+			go runSchedule(projectName, project, name, pipeline.Schedule, stopSchedules, l)
+		}
+	}
+}
+
+// StopSchedules - stops scheduling builds, has to be called before queues are drained
+func StopSchedules() {
+	if stopSchedules == nil {
+		return
+	}
+	close(stopSchedules)
+	schedulesWg.Wait()
+	stopSchedules = nil
+}
+
+func runSchedule(projectName string, project Project, pipeline string, schedule string, stop chan struct{}, l *log.Logger) {
+	defer schedulesWg.Done()
+	for {
+		next, err := NextRun(schedule, time.Now())
+		if err != nil {
+			l.Printf("project %s pipeline %s: %v\n", projectName, pipeline, err)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			build := NewBuild(projectName, project, pipeline, Trigger{Event: EVENT_SCHEDULE})
+			if !Enqueue(build) {
+				l.Printf("project %s pipeline %s: scheduled build skipped, build queue is full\n", projectName, pipeline)
+			}
+		}
+	}
+}
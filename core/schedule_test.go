@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	// a wednesday
+	from := time.Date(2023, 3, 15, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		from     time.Time
+		want     time.Time
+		err      string
+	}{
+		{schedule: "@every 90m", from: from, want: from.Add(90 * time.Minute)},
+		{schedule: "@every 30s", from: from, err: "at least a minute"},
+		{schedule: "@every soon", from: from, err: "invalid schedule"},
+		{schedule: "@hourly", from: from, want: time.Date(2023, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{schedule: "@daily", from: from, want: time.Date(2023, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{schedule: "@midnight", from: from, want: time.Date(2023, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{schedule: "@weekly", from: from, want: time.Date(2023, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{schedule: "@weekly", from: time.Date(2023, 3, 19, 0, 0, 0, 0, time.UTC), want: time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC)},
+		{schedule: "12:00", from: from, want: time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{schedule: "04:15", from: from, want: time.Date(2023, 3, 16, 4, 15, 0, 0, time.UTC)},
+		{schedule: "10:30", from: time.Date(2023, 3, 15, 10, 30, 0, 0, time.UTC), want: time.Date(2023, 3, 16, 10, 30, 0, 0, time.UTC)},
+		{schedule: "12:00", from: time.Date(2023, 3, 15, 12, 0, 0, 0, time.FixedZone("CET", 3600)), want: time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{schedule: "25:00", from: from, err: "expected @every"},
+		{schedule: "", from: from, err: "expected @every"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			got, err := NextRun(tt.schedule, tt.from)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("NextRun() error = %v, want error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextRun() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextRun(%q, %v) = %v, want %v", tt.schedule, tt.from, got, tt.want)
+			}
+		})
+	}
+}
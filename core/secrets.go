@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// references to secrets kept out of config, ${env:NAME} is replaced with environment variable NAME
+// and ${file:/path} with contents of the file (without trailing newline)
+var secretRef = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// resolveSecret - replaces secret references of value, errors name the reference but never the value
+func resolveSecret(value string) (string, error) {
+	var err error
+	resolved := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+		m := secretRef.FindStringSubmatch(ref)
+		kind, name := m[1], strings.TrimSpace(m[2])
+		switch kind {
+		case "env":
+			v, ok := os.LookupEnv(name)
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return v
+		default:
+			v, ferr := readSecretFile(name)
+			if ferr != nil && err == nil {
+				err = ferr
+			}
+			return v
+		}
+	})
+	return resolved, err
+}
+
+func readSecretFile(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("secret file: %v", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveSecrets - resolves webhook secrets, api tokens, share secrets and step env values,
+// done on every load so that reload picks up rotated secrets
+func resolveSecrets(conf *Doc) error {
+	if err := conf.Auth.resolveSecrets(); err != nil {
+		return fmt.Errorf("auth: %v", err)
+	}
+	for projectName, project := range conf.Project {
+		var err error
+		if project.SecretFile != "" {
+			if project.Secret != "" {
+				return fmt.Errorf("project %s: secret and secretFile are both set", projectName)
+			}
+			if project.Secret, err = readSecretFile(project.SecretFile); err != nil {
+				return fmt.Errorf("project %s: %v", projectName, err)
+			}
+		} else if project.Secret, err = resolveSecret(project.Secret); err != nil {
+			return fmt.Errorf("project %s secret: %v", projectName, err)
+		}
+		if project.Auth != nil {
+			auth := *project.Auth
+			if err := auth.resolveSecrets(); err != nil {
+				return fmt.Errorf("project %s auth: %v", projectName, err)
+			}
+			project.Auth = &auth
+		}
+		// env is shared with [defaults] until resolved
+		env := make(map[string]string, len(project.Env))
+		for name, value := range project.Env {
+			if env[name], err = resolveSecret(value); err != nil {
+				return fmt.Errorf("project %s env %s: %v", projectName, name, err)
+			}
+		}
+		project.Env = env
+		conf.Project[projectName] = project
+	}
+	return nil
+}
+
+func (a *AuthConf) resolveSecrets() error {
+	var err error
+	if a.Tokens, err = resolveTokens("tokens", a.Tokens); err != nil {
+		return err
+	}
+	if a.ReadTokens, err = resolveTokens("readTokens", a.ReadTokens); err != nil {
+		return err
+	}
+	if a.ShareSecret, err = resolveSecret(a.ShareSecret); err != nil {
+		return fmt.Errorf("shareSecret: %v", err)
+	}
+	return nil
+}
+
+func resolveTokens(key string, tokens []string) ([]string, error) {
+	resolved := make([]string, len(tokens))
+	for i, token := range tokens {
+		var err error
+		if resolved[i], err = resolveSecret(token); err != nil {
+			return nil, fmt.Errorf("%s[%d]: %v", key, i, err)
+		}
+	}
+	return resolved, nil
+}
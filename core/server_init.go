@@ -2,9 +2,13 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"ghhooks.com/hook/jobqueue"
@@ -12,15 +16,91 @@ import (
 )
 
 type Doc struct {
-	Project map[string]Project `toml:"project"`
+	Project    map[string]Project `toml:"project"`
+	Defaults   Defaults           `toml:"defaults"`
+	Deliveries DeliveryConf       `toml:"deliveries"`
+	History    HistoryConf        `toml:"history"`
+	Auth       AuthConf           `toml:"auth"`
+	TLS        TLSConf            `toml:"tls"`
+	// directory with templates that replace the built in status page and dashboard
+	Templates string `toml:"templates"`
+	// prefix of every route, for serving under a path of a reverse proxy
+	BasePath string `toml:"basePath"`
+	// proxies whose X-Forwarded-For, -Host and -Proto headers are honored, DefaultTrustedProxies when empty
+	TrustedProxies  []string `toml:"trustedProxies"`
+	trustedNetworks []*net.IPNet
+}
+
+// TrustedNetworks - parsed trustedProxies
+func (d Doc) TrustedNetworks() []*net.IPNet {
+	return d.trustedNetworks
 }
 
 type Project struct {
-	Branch      string     `toml:"branch"`
-	Secret      string     `toml:"secret"`
+	Branch string `toml:"branch"`
+	Secret string `toml:"secret"`
+	// file the webhook secret is read from instead of secret
+	SecretFile  string     `toml:"secretFile"`
 	Cwd         string     `toml:"cwd"`
 	Steps       [][]string `toml:"steps"`
 	StepTimeout int        `toml:"stepTimeout"`
+	SkipMarkers []string   `toml:"skipMarkers"`
+	ForceBuild  bool       `toml:"forceBuild"`
+	PRSteps     [][]string `toml:"prSteps"`
+	PRBranches  []string   `toml:"prBranches"`
+
+	// steps run when configured branch is deleted, deletions are ignored when empty
+	TeardownSteps [][]string `toml:"teardownSteps"`
+
+	ReleaseActions  []string `toml:"releaseActions"`
+	ReleaseTags     []string `toml:"releaseTags"`
+	SkipPrereleases bool     `toml:"skipPrereleases"`
+
+	// named pipelines, when set steps, prSteps and teardownSteps are not used
+	Pipeline map[string]Pipeline `toml:"pipeline"`
+
+	// replaces global auth for this project when set
+	Auth *AuthConf `toml:"auth"`
+	// webhook deliveries are only accepted from these addresses (CIDR ranges), from anywhere when empty
+	AllowedIPs []string `toml:"allowedIPs"`
+
+	// steps are also read from this file (relative to cwd) after the configured steps ran, opt-in
+	PipelineFile string `toml:"pipelineFile"`
+	// path.Match patterns of commands and env names pipeline file may use, and its step timeout cap
+	AllowedCommands []string `toml:"allowedCommands"`
+	AllowedEnv      []string `toml:"allowedEnv"`
+	MaxStepTimeout  int      `toml:"maxStepTimeout"`
+
+	// inherited from [defaults] when not set
+	Shell     []string          `toml:"shell"`
+	Env       map[string]string `toml:"env"`
+	QueueSize int               `toml:"queueSize"`
+}
+
+// markers that skip a push build when found in commit messages, used when project has no skipMarkers configured
+var DefaultSkipMarkers = []string{"[skip ci]", "[ci skip]", "[skip deploy]"}
+
+func (p Project) Markers() []string {
+	if len(p.SkipMarkers) == 0 {
+		return DefaultSkipMarkers
+	}
+	return p.SkipMarkers
+}
+
+// release actions that are built when project has no releaseActions configured
+var DefaultReleaseActions = []string{"published"}
+
+func (p Project) AcceptsReleaseAction(action string) bool {
+	actions := p.ReleaseActions
+	if len(actions) == 0 {
+		actions = DefaultReleaseActions
+	}
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
 }
 
 // result processing is local to individual job
@@ -28,35 +108,82 @@ type Project struct {
 // map key in resultSyncMap is projectID, the reason behind this is to have independent project build results
 
 type Result struct {
-	Error       error  `json:"error"`
-	Output      string `json:"output"`
-	Description string `json:"description"`
+	Error       error   `json:"error"`
+	Output      string  `json:"output"`
+	Description string  `json:"description"`
+	Duration    float64 `json:"duration"`
 }
 
 // DONE: build status
 type JobState struct {
+	ID             int64     `json:"id"`
 	LastBuildStart time.Time `json:"lastBuildStart"`
 	StepResults    []Result  `json:"stepResults"`
 	BuildStatus    string    `json:"buildStatus"`
+	FinishedAt     time.Time `json:"finishedAt"`
+	Pipeline       string    `json:"pipeline"`
+	// steps that were run for the build, pipeline steps may change after the build
+	Steps   [][]string `json:"steps"`
+	Trigger Trigger    `json:"trigger"`
+	// steps read from pipeline file of repository, they are also part of steps
+	Rendered *RenderedPipeline `json:"rendered,omitempty"`
+}
+
+// SkipRecord keeps track of last push event that was acknowledged but not built
+type SkipRecord struct {
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit"`
+	Reason string    `json:"reason"`
 }
 
+// Map keeps last build of every pipeline by project and pipeline name, PRs keeps last build of
+// every pull request by project and pull request number
 type ResultSyncMap struct {
-	Mu  sync.RWMutex
-	Map map[string]JobState
+	Mu    sync.RWMutex
+	Map   map[string]map[string]JobState
+	Skips map[string]SkipRecord
+	PRs   map[string]map[int]JobState
+}
+
+// State - returns last build of project pipeline
+func (rm *ResultSyncMap) State(project, pipeline string) (JobState, bool) {
+	rm.Mu.RLock()
+	defer rm.Mu.RUnlock()
+	state, ok := rm.Map[project][pipeline]
+	return state, ok
 }
 
 const (
+	QUEUED  string = "queued"
 	PENDING string = "pending"
 	FAILED  string = "failed"
 	SUCCESS string = "success"
 )
 
+// Finished - reports if build has either failed or succeeded
+func (js JobState) Finished() bool {
+	return js.BuildStatus == FAILED || js.BuildStatus == SUCCESS
+}
+
 // GLobals
+// Queues and LiveFeeds change on reload, they are only accessed through ProjectQueue and PipelineFeed
 var Queues jobqueue.QueueMap
-var ServerConf Doc
 var ResultMap *ResultSyncMap
 var Ctx context.Context
-var LiveResultUpdates map[string]chan JobState
+var LiveFeeds map[string]map[string]*LiveFeed
+
+// updates of every build of every project, for the dashboard
+var DashboardFeed *LiveFeed
+
+var Deliveries *DeliveryLog
+var History *BuildHistory
+
+var lastBuildID int64
+
+// NextBuildID - returns id for a new build, ids are unique for the lifetime of the process
+func NextBuildID() int64 {
+	return atomic.AddInt64(&lastBuildID, 1)
+}
 
 // function that will be enqued by project specific queue
 // DONE: make this func fit into queue job function prototype
@@ -66,42 +193,129 @@ var LiveResultUpdates map[string]chan JobState
 // DONE: along with error object add error description too (err.Error())
 // TODO: cancel running build
 
+// ConfigParser - LoadConfig without problems, config is only returned once secrets were resolved and
+// it was validated
 func ConfigParser(fileLocation string) (Doc, error) {
+	doc, _, err := LoadConfig(fileLocation)
+	return doc, err
+}
+
+// parseConfig - config location is a file or a directory of *.toml, *.yaml and *.json files, keys that were not decoded
+// are returned as problems
+func parseConfig(location string) (Doc, []Problem, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return Doc{}, nil, err
+	}
+	if info.IsDir() {
+		return parseConfigDir(location)
+	}
+	format := ConfigFormat
+	if format == "" {
+		format = FormatOf(location)
+	}
+	doc, md, err := decodeConfigFile(location, format)
+	return doc, unknownKeys(md, ""), err
+}
+
+func decodeConfigFile(fileLocation string, format string) (Doc, toml.MetaData, error) {
 	var doc Doc
 	b, err := ioutil.ReadFile(fileLocation)
 	if err != nil {
-		return doc, err
+		return doc, toml.MetaData{}, err
 	}
-	_, err = toml.Decode(string(b), &doc)
+	md, err := decodeConfig(b, format, &doc)
+	return doc, md, err
+}
+
+// LoadConfig - parses and validates config file, problems are mistakes that do not stop ghhooks from running
+func LoadConfig(fileLocation string) (Doc, []Problem, error) {
+	conf, problems, err := parseConfig(fileLocation)
 	if err != nil {
-		return doc, err
+		return conf, nil, err
 	}
-	return doc, nil
-
+	conf.applyDefaults()
+	if err := resolveSecrets(&conf); err != nil {
+		return conf, nil, err
+	}
+	if err := validateConfig(&conf); err != nil {
+		return conf, nil, err
+	}
+	return conf, append(problems, CheckConfig(conf)...), nil
 }
 
-func ServerInit(configlocation string, l *log.Logger, wg *sync.WaitGroup) error {
-	conf, err := ConfigParser(configlocation)
+// loadCheckedConfig - loads config and logs its problems, with StrictConfig problems are an error
+func loadCheckedConfig(fileLocation string, l *log.Logger) (Doc, error) {
+	conf, problems, err := LoadConfig(fileLocation)
 	if err != nil {
+		return conf, err
+	}
+	for _, problem := range problems {
+		l.Printf("config: %s\n", problem)
+	}
+	if StrictConfig && len(problems) > 0 {
+		return conf, fmt.Errorf("config has %d problems, run hook validate for details", len(problems))
+	}
+	return conf, nil
+}
+
+// validateConfig - checks config before it is used, normalizes basePath and parses trusted proxies
+func validateConfig(conf *Doc) error {
+	var err error
+	if err := conf.Auth.Validate(); err != nil {
+		return fmt.Errorf("auth: %v", err)
+	}
+	if conf.BasePath, err = normalizeBasePath(conf.BasePath); err != nil {
 		return err
 	}
-	ServerConf = conf
-	Queues = make(jobqueue.QueueMap, 0)
-	LiveResultUpdates = make(map[string]chan JobState)
-	for projectName, project := range ServerConf.Project {
-		jg := jobqueue.NewJobQueue(projectName, make(chan jobqueue.Job, 25), 1, l, wg)
-		err = Queues.Register(jg)
-		if err != nil {
-			return err
+	trusted := conf.TrustedProxies
+	if len(trusted) == 0 {
+		trusted = DefaultTrustedProxies
+	}
+	if conf.trustedNetworks, err = ParseNetworks(trusted); err != nil {
+		return fmt.Errorf("trustedProxies: %v", err)
+	}
+	for projectName, project := range conf.Project {
+		if _, err := ParseNetworks(project.AllowedIPs); err != nil {
+			return fmt.Errorf("project %s allowedIPs: %v", projectName, err)
 		}
-		LiveResultUpdates[projectName] = make(chan JobState, len(project.Steps)+1)
+		if project.Auth != nil {
+			if err := project.Auth.Validate(); err != nil {
+				return fmt.Errorf("project %s auth: %v", projectName, err)
+			}
+		}
+		for name, pipeline := range project.Pipelines() {
+			if pipeline.Schedule != "" {
+				if _, err := NextRun(pipeline.Schedule, time.Now()); err != nil {
+					return fmt.Errorf("project %s pipeline %s: %v", projectName, name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
 
+func ServerInit(configlocation string, l *log.Logger, wg *sync.WaitGroup) error {
+	conf, err := loadCheckedConfig(configlocation, l)
+	if err != nil {
+		return err
 	}
-	Queues.StartAll()
+	configLocation, logger, workers = configlocation, l, wg
+
+	Queues = make(jobqueue.QueueMap, 0)
+	LiveFeeds = make(map[string]map[string]*LiveFeed)
+	DashboardFeed = NewLiveFeed()
 	ResultMap = &ResultSyncMap{
-		Map: make(map[string]JobState),
+		Map:   make(map[string]map[string]JobState),
+		Skips: make(map[string]SkipRecord),
+		PRs:   make(map[string]map[int]JobState),
 	}
 
+	Deliveries = NewDeliveryLog(conf.Deliveries)
+	History = NewBuildHistory(conf.History.Limit)
+
 	Ctx = context.Background()
+	applyConfig(conf)
+	StartSchedules(l)
 	return nil
 }
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+type TLSConf struct {
+	Cert string `toml:"cert"`
+	Key  string `toml:"key"`
+	// "1.0", "1.1", "1.2" or "1.3", defaults to 1.2
+	MinVersion string `toml:"minVersion"`
+	// pem bundle of CAs client certificates are verified against, enables mutual tls when set
+	ClientCA string `toml:"clientCA"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Enabled - https is served when both certificate and key are configured
+func (t TLSConf) Enabled() bool {
+	return t.Cert != "" && t.Key != ""
+}
+
+func (t TLSConf) Validate() error {
+	if (t.Cert == "") != (t.Key == "") {
+		return fmt.Errorf("tls needs both cert and key")
+	}
+	_, err := t.Version()
+	return err
+}
+
+// Version - minimum tls version as crypto/tls constant
+func (t TLSConf) Version() (uint16, error) {
+	if t.MinVersion == "" {
+		return tls.VersionTLS12, nil
+	}
+	version, ok := tlsVersions[t.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version %q, expected one of 1.0, 1.1, 1.2, 1.3", t.MinVersion)
+	}
+	return version, nil
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// StrictConfig - refuse to start or reload when config has problems instead of logging them
+var StrictConfig bool
+
+// Problem is something in config that is likely a mistake but does not stop ghhooks from running
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+var knownEvents = map[string]bool{
+	EVENT_PUSH:         true,
+	EVENT_DELETE:       true,
+	EVENT_RELEASE:      true,
+	EVENT_PULL_REQUEST: true,
+	EVENT_SCHEDULE:     true,
+}
+
+// unknownKeys - keys that were not decoded, mostly typos, file is named when config is split across files
+func unknownKeys(md toml.MetaData, file string) []Problem {
+	problems := make([]Problem, 0)
+	message := "unknown key, it is ignored"
+	if file != "" {
+		message = "unknown key in " + file + ", it is ignored"
+	}
+	for _, key := range md.Undecoded() {
+		problems = append(problems, Problem{Key: key.String(), Message: message})
+	}
+	return problems
+}
+
+// CheckConfig - looks for missing cwd directories, step commands that can not be found, empty secrets
+// and invalid branch and tag patterns
+func CheckConfig(conf Doc) []Problem {
+	problems := make([]Problem, 0)
+
+	projectNames := make([]string, 0, len(conf.Project))
+	for name := range conf.Project {
+		projectNames = append(projectNames, name)
+	}
+	sort.Strings(projectNames)
+	for _, projectName := range projectNames {
+		problems = append(problems, checkProject(projectName, conf.Project[projectName])...)
+	}
+	return problems
+}
+
+func checkProject(projectName string, project Project) []Problem {
+	problems := make([]Problem, 0)
+	key := "project." + projectName
+	add := func(k string, format string, args ...any) {
+		problems = append(problems, Problem{Key: k, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if project.Secret == "" {
+		add(key+".secret", "secret is empty, webhook signatures can not be verified")
+	}
+	if project.Cwd == "" {
+		add(key+".cwd", "cwd is not set, steps run in the directory ghhooks was started from")
+	} else if info, err := os.Stat(project.Cwd); err != nil {
+		add(key+".cwd", "%v", err)
+	} else if !info.IsDir() {
+		add(key+".cwd", "%s is not a directory", project.Cwd)
+	}
+	checkRepoPolicy(add, key, project)
+	for _, pattern := range project.PRBranches {
+		checkPattern(add, key+".prBranches", pattern)
+	}
+	for _, pattern := range project.ReleaseTags {
+		checkPattern(add, key+".releaseTags", pattern)
+	}
+
+	pipelines := project.Pipelines()
+	for _, name := range project.PipelineNames() {
+		pipeline := pipelines[name]
+		pipelineKey := key + ".pipeline." + name
+		if len(project.Pipeline) == 0 {
+			// legacy steps are reported under their own keys
+			pipelineKey = key
+		}
+		for _, event := range pipeline.On {
+			if !knownEvents[event] {
+				add(pipelineKey+".on", "unknown event %q", event)
+			}
+		}
+		if len(project.Pipeline) > 0 {
+			for _, pattern := range pipeline.Branches {
+				checkPattern(add, pipelineKey+".branches", pattern)
+			}
+			for _, pattern := range pipeline.Tags {
+				checkPattern(add, pipelineKey+".tags", pattern)
+			}
+		}
+		if len(pipeline.Steps) == 0 && project.PipelineFile == "" {
+			add(pipelineKey, "pipeline %s has no steps", name)
+		}
+		for i, step := range pipeline.Steps {
+			stepKey := fmt.Sprintf("%s.steps[%d]", pipelineKey, i)
+			if len(project.Pipeline) == 0 && name != DEFAULT_PIPELINE {
+				stepKey = fmt.Sprintf("%s.%sSteps[%d]", key, name, i)
+			}
+			if len(step) == 0 {
+				add(stepKey, "step is empty")
+				continue
+			}
+			command, _ := project.command(step)
+			if err := lookCommand(command, project.Cwd); err != nil {
+				add(stepKey, "%v", err)
+			}
+		}
+	}
+	return problems
+}
+
+func checkPattern(add func(string, string, ...any), key string, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		add(key, "invalid pattern %q: %v", pattern, err)
+	}
+}
+
+// lookCommand - commands with a path are resolved against cwd the way steps run them, others against PATH
+func lookCommand(command string, cwd string) error {
+	if filepath.Base(command) != command && !filepath.IsAbs(command) {
+		command = filepath.Join(cwd, command)
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("command %s not found", command)
+	}
+	return nil
+}
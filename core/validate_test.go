@@ -0,0 +1,106 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func problemKeys(problems []Problem) []string {
+	keys := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		keys = append(keys, problem.Key)
+	}
+	return keys
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	ok := Project{Branch: "main", Secret: "x", Cwd: dir, Steps: [][]string{{"sh", "-c", "true"}, {"./deploy.sh"}}}
+
+	tests := []struct {
+		name    string
+		project Project
+		want    []string
+	}{
+		{name: "valid", project: ok, want: []string{}},
+		{
+			name:    "missing secret and cwd",
+			project: Project{Branch: "main", Steps: [][]string{{"sh"}}},
+			want:    []string{"project.p.secret", "project.p.cwd"},
+		},
+		{
+			name:    "cwd is a file",
+			project: Project{Branch: "main", Secret: "x", Cwd: file, Steps: [][]string{{"sh"}}},
+			want:    []string{"project.p.cwd"},
+		},
+		{
+			name: "legacy steps",
+			project: Project{Branch: "main", Secret: "x", Cwd: dir,
+				Steps:         [][]string{{}, {"no-such-command-xyz"}},
+				PRSteps:       [][]string{{"./missing.sh"}},
+				PRBranches:    []string{"[main"},
+				TeardownSteps: [][]string{{"sh"}},
+				ReleaseTags:   []string{"v["},
+			},
+			want: []string{"project.p.prBranches", "project.p.releaseTags", "project.p.steps[0]", "project.p.steps[1]", "project.p.prSteps[0]"},
+		},
+		{
+			name: "named pipelines",
+			project: Project{Branch: "main", Secret: "x", Cwd: dir, Pipeline: map[string]Pipeline{
+				"deploy": {On: []string{"push", "tag"}, Branches: []string{"main", "[x"}, Steps: [][]string{{"sh"}}},
+				"empty":  {On: []string{"release"}, Tags: []string{"v["}},
+			}},
+			want: []string{"project.p.pipeline.deploy.on", "project.p.pipeline.deploy.branches", "project.p.pipeline.empty.tags", "project.p.pipeline.empty"},
+		},
+		{
+			name:    "shell checks the shell",
+			project: Project{Branch: "main", Secret: "x", Cwd: dir, Shell: []string{"no-such-shell-xyz", "-c"}, Steps: [][]string{{"make && make install"}}},
+			want:    []string{"project.p.steps[0]"},
+		},
+		{
+			name:    "pipeline file policy",
+			project: Project{Branch: "main", Secret: "x", Cwd: dir, Steps: [][]string{{"sh"}}, PipelineFile: "/abs/.ghhooks.toml", AllowedEnv: []string{"["}, MaxStepTimeout: -1},
+			want:    []string{"project.p.pipelineFile", "project.p.allowedEnv", "project.p.allowedCommands", "project.p.maxStepTimeout"},
+		},
+		{
+			name:    "policy without pipeline file",
+			project: Project{Branch: "main", Secret: "x", Cwd: dir, Steps: [][]string{{"sh"}}, AllowedCommands: []string{"*"}},
+			want:    []string{"project.p.pipelineFile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := problemKeys(CheckConfig(Doc{Project: map[string]Project{"p": tt.project}}))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	config := "basePth = \"/x\"\n[project.p]\nbranch = \"main\"\nsecret = \"x\"\ncwd = '" + dir + "'\nsteps = [[\"sh\"]]\nstepTimout = 5\n"
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, problems, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"basePth", "project.p.stepTimout"}
+	if got := problemKeys(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfig() problems = %v, want %v", got, want)
+	}
+}
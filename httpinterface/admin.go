@@ -0,0 +1,34 @@
+package httpinterface
+
+import (
+	"net/http"
+
+	"ghhooks.com/hook/core"
+)
+
+// Reload - loads config file again, same as sending SIGHUP. needs global auth to be configured since
+// it is the only endpoint that acts on the whole server
+func Reload(w http.ResponseWriter, r *http.Request) {
+	if !core.Conf().Auth.Enabled() {
+		Respond(w, 403, map[string]interface{}{
+			"error": "reload endpoint needs [auth] users or tokens to be configured",
+		})
+		return
+	}
+	if !Authorize(w, r, "", core.ACCESS_CONTROL) {
+		return
+	}
+	res, err := core.Reload()
+	if err != nil {
+		Respond(w, 422, map[string]interface{}{
+			"error": "config was not reloaded: " + err.Error(),
+		})
+		return
+	}
+	Respond(w, 200, map[string]interface{}{
+		"message": "config reloaded",
+		"added":   res.Added,
+		"removed": res.Removed,
+		"resized": res.Resized,
+	})
+}
@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"ghhooks.com/hook/core"
-	"ghhooks.com/hook/jobqueue"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
@@ -22,16 +23,17 @@ import (
 // DONE: create global resultmap in core package for keeping track of build results
 // DONE: status route
 // TODO: github commit status
-// TODO: blocking build run
+// DONE: blocking build run
 // DONE: html page for status
-// TODO: maybe put password on status page to prevent from builds being cancelled by just anyone
+// DONE: maybe put password on status page to prevent from builds being cancelled by just anyone
 // DONE: update progressbar using websockets,
 // DONE: individual step results on statuspage
-// TODO: investigate what happens if websocket events come faster than the time it takes for event to process
+// DONE: investigate what happens if websocket events come faster than the time it takes for event to process
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	CheckOrigin:     CheckOrigin,
 }
 
 const (
@@ -50,12 +52,20 @@ type Step struct {
 type StatusResponse struct {
 	core.JobState
 	ProjectName    string       `json:"projectName"`
+	Pipelines      []string     `json:"pipelines"`
 	DateTimeString string       `json:"dateTimeString"`
 	Coverage       float64      `json:"coverage"`
 	WebSocketRoute template.URL `json:"websocketRoute"`
+	EventsRoute    template.URL `json:"eventsRoute"`
+	Share          string       `json:"share,omitempty"`
 	Steps          []Step       `json:"steps"`
 }
 
+type JSONStatusResponse struct {
+	core.JobState
+	LastSkip *core.SkipRecord `json:"lastSkip,omitempty"`
+}
+
 type WebsocketResponse struct {
 	core.JobState
 	Coverage    float64 `json:"coverage"`
@@ -72,13 +82,6 @@ func WebHookListener(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	project, ok := core.ServerConf.Project[projectID]
-	if !ok {
-		Respond(w, 400, map[string]interface{}{
-			"error": "no project found with given project name",
-		})
-		return
-	}
 
 	bodyInBytes, err := StreamToByte(r.Body)
 	if err != nil {
@@ -88,53 +91,51 @@ func WebHookListener(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash := r.Header.Get("X-Hub-Signature-256")
-	if hash != "" {
-		verified, err := VerifySignature(bodyInBytes, hash, project.Secret)
-		if err != nil {
-			Respond(w, 500, map[string]any{
-				"error": err.Error(),
-			})
-			return
-		}
-		if !verified {
-			Respond(w, 412, map[string]any{
-				"error": "signauture could not be verified",
-			})
-			return
-		}
+	if project, ok := core.Conf().Project[projectID]; ok && !IPAllowed(r, project) {
+		Respond(w, 403, map[string]interface{}{
+			"error": "webhook deliveries are not accepted from this address",
+		})
+		return
 	}
 
-	eventType := r.Header.Get("X-GitHub-Event")
-	err = VerifyEvent(eventType, bodyInBytes, project.Branch)
+	delivery := core.Delivery{
+		GUID:       r.Header.Get("X-GitHub-Delivery"),
+		Project:    projectID,
+		Event:      r.Header.Get("X-GitHub-Event"),
+		ReceivedAt: time.Now().UTC(),
+		Headers:    core.DeliveryHeaders(r.Header),
+		Body:       string(bodyInBytes),
+	}
+	statusCode, res := ProcessDelivery(&delivery)
+	core.Deliveries.Add(delivery)
+	RespondOrWait(w, r, statusCode, res, delivery.BuildIDs)
+}
 
+// RespondOrWait - responds right away unless ?wait=true is set and builds were queued, in that case
+// response is sent once all builds have finished with their final states
+func RespondOrWait(w http.ResponseWriter, r *http.Request, statusCode int, res map[string]any, buildIDs []int64) {
+	if r.URL.Query().Get("wait") != "true" || statusCode != 201 || len(buildIDs) == 0 {
+		Respond(w, statusCode, res)
+		return
+	}
+	timeout, err := WaitTimeout(r)
 	if err != nil {
 		Respond(w, 400, map[string]interface{}{
-			"error": fmt.Sprintf("error: %v.", err),
+			"error": "invalid timeout, expected seconds",
 		})
 		return
 	}
-
-	// enqueing job
-	enqueueStatus := core.Queues.Enqueue(projectID, jobqueue.Job{
-		Name:   "build",
-		Action: core.Job,
-		Args: []any{
-			projectID,
-			project,
-		},
-	})
-	if !enqueueStatus {
-		Respond(
-			w, 429, map[string]interface{}{
-				"error": "build queue is full",
-			},
-		)
-		return
+	states, statusCode := WaitForBuilds(r.Context(), timeout, buildIDs)
+	res["builds"] = states
+	switch statusCode {
+	case 200:
+		res["message"] = "build finished successfully"
+	case 422:
+		res["message"] = "build failed"
+	default:
+		res["message"] = "build did not finish before timeout"
 	}
-	Respond(w, 201, map[string]interface{}{
-		"message": "build queued successfully",
-	})
+	Respond(w, statusCode, res)
 }
 
 func BuildStatus(w http.ResponseWriter, r *http.Request) {
@@ -148,7 +149,7 @@ func BuildStatus(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	project, ok := core.ServerConf.Project[projectID]
+	project, ok := core.Conf().Project[projectID]
 	if !ok {
 		Respond(w, 400, map[string]interface{}{
 			"error": "no project found with given project name",
@@ -156,16 +157,32 @@ func BuildStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	totalSteps := len(project.Steps)
-	if totalSteps == 0 {
+	pipelineName, pipeline, ok := PipelineFromQuery(r, project)
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no pipeline found with given pipeline name",
+		})
+		return
+	}
+
+	if len(pipeline.Steps) == 0 && project.PipelineFile == "" {
 		Respond(w, http.StatusBadRequest, map[string]interface{}{
 			"error": "no build steps configured",
 		})
 		return
 	}
+	result, ok := core.ResultMap.State(projectID, pipelineName)
 	core.ResultMap.Mu.RLock()
-	result, ok := core.ResultMap.Map[projectID]
+	skip, skipped := core.ResultMap.Skips[projectID]
 	core.ResultMap.Mu.RUnlock()
+	if !ok && skipped {
+		// pushes skipped before the first build are still reported
+		Respond(w, 200, map[string]interface{}{
+			"message":  "no build have been run yet",
+			"lastSkip": skip,
+		})
+		return
+	}
 	if !ok {
 		Respond(w, http.StatusBadRequest, map[string]interface{}{
 			"error": "no build have been run yet, or nothing to report on the project",
@@ -180,17 +197,29 @@ func BuildStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	coverage := float64(successfullSteps * 100 / totalSteps)
+	// steps of the build, which also include the ones read from pipeline file
+	steps := result.Steps
+	if steps == nil {
+		steps = pipeline.Steps
+	}
+	var coverage float64
+	if len(steps) > 0 {
+		coverage = float64(successfullSteps * 100 / len(steps))
+	}
 
 	format := r.URL.Query().Get("format")
 
 	if format == "json" {
-		Respond(w, 200, result)
+		res := JSONStatusResponse{JobState: result}
+		if skipped {
+			res.LastSkip = &skip
+		}
+		Respond(w, 200, res)
 		return
 	}
 
 	projectSteps := make([]Step, 0)
-	for i, step := range project.Steps {
+	for i, step := range steps {
 		step_ := Step{
 			Command: strings.Join(step, " "),
 			Status:  PENDING_MARK,
@@ -210,41 +239,91 @@ func BuildStatus(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	websocketScheme := "ws"
+	if RequestScheme(r) == "https" {
+		websocketScheme = "wss"
+	}
+	// share token is passed on so that live updates work on shared pages
+	query := url.QueryEscape(pipelineName) + shareQuery(r)
 	templateResponse := StatusResponse{
 		JobState:       result,
 		ProjectName:    projectID,
+		Pipelines:      project.PipelineNames(),
 		DateTimeString: result.LastBuildStart.Format(time.RFC3339),
 		Coverage:       coverage,
-		WebSocketRoute: template.URL(fmt.Sprintf("ws://%s/%s/livestatus", r.Host, projectID)),
+		WebSocketRoute: template.URL(fmt.Sprintf("%s://%s%s/%s/livestatus?pipeline=%s", websocketScheme, r.Host, BasePath(), projectID, query)),
+		EventsRoute:    template.URL(fmt.Sprintf("%s/%s/events?pipeline=%s", BasePath(), projectID, query)),
+		Share:          r.URL.Query().Get("share"),
 		Steps:          projectSteps,
 	}
 
-	tmpl := template.Must(template.ParseFiles("statuspage.html"))
-	tmpl.Execute(w, templateResponse)
+	renderTemplate(w, "statuspage.html", templateResponse)
 
 }
 
-func LiveStatusUpdate(w http.ResponseWriter, r *http.Request) {
-	//NOTE: to debug script on statuspage add //# sourceURL=statuspage at end of script above closing tag
+type PipelineStatus struct {
+	core.Pipeline
+	LastBuild *core.JobState `json:"lastBuild"`
+}
 
-	vars := mux.Vars(r)
-	projectID, ok := vars["project"]
+// PipelinesStatus - reports every pipeline of project along with its last build
+func PipelinesStatus(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	project, ok := core.Conf().Project[projectID]
 	if !ok {
 		Respond(w, 400, map[string]interface{}{
-			"error": "no vars found",
+			"error": "no project found with given project name",
 		})
 		return
 	}
 
-	project, ok := core.ServerConf.Project[projectID]
-	if !ok {
+	res := make(map[string]PipelineStatus)
+	for name, pipeline := range project.Pipelines() {
+		status := PipelineStatus{Pipeline: pipeline}
+		if state, ok := core.ResultMap.State(projectID, name); ok {
+			status.LastBuild = &state
+		}
+		res[name] = status
+	}
+	Respond(w, 200, res)
+}
+
+func PullRequestStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["project"]
+	if _, ok := core.Conf().Project[projectID]; !ok {
 		Respond(w, 400, map[string]interface{}{
 			"error": "no project found with given project name",
 		})
 		return
 	}
+	number, err := strconv.Atoi(vars["number"])
+	if err != nil {
+		Respond(w, 400, map[string]interface{}{
+			"error": "invalid pull request number",
+		})
+		return
+	}
+
+	core.ResultMap.Mu.RLock()
+	result, ok := core.ResultMap.PRs[projectID][number]
+	core.ResultMap.Mu.RUnlock()
+	if !ok {
+		Respond(w, http.StatusBadRequest, map[string]interface{}{
+			"error": "no build have been run yet for given pull request",
+		})
+		return
+	}
+	Respond(w, 200, result)
+}
+
+func LiveStatusUpdate(w http.ResponseWriter, r *http.Request) {
+	//NOTE: to debug script on statuspage add //# sourceURL=statuspage at end of script above closing tag
 
-	totalSteps := len(project.Steps)
+	projectID, feed, ok := liveFeedFromRequest(w, r)
+	if !ok {
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -255,35 +334,58 @@ func LiveStatusUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	for jobState := range core.LiveResultUpdates[projectID] {
+	websocketSubscribers.Inc(projectID)
+	defer websocketSubscribers.Dec(projectID)
 
-		var successfullSteps int
-		for _, v := range jobState.StepResults {
-			if v.Error == nil {
-				successfullSteps = successfullSteps + 1
+	updates, _, cancel := feed.Subscribe(0)
+	defer cancel()
+
+	// nothing is expected from the client, reading only to find out when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
 			}
 		}
+	}()
 
-		coverage := float64(successfullSteps * 100 / totalSteps)
-
-		res := WebsocketResponse{
-			JobState:    jobState,
-			ProjectName: projectID,
-			Coverage:    coverage,
-		}
-		err := conn.WriteJSON(res)
-		if err != nil {
-			break
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-updates:
+			err := conn.WriteJSON(NewWebsocketResponse(projectID, event.State))
+			if err != nil {
+				return
+			}
 		}
 	}
-
 }
 
 func RouterInit(r *mux.Router) {
+	APIRouterInit(r)
+	r.HandleFunc("/healthz", Healthz).Methods("GET")
+	r.HandleFunc("/readyz", Readyz).Methods("GET")
+	r.PathPrefix("/static/").Handler(Static()).Methods("GET")
+	r.HandleFunc("/", Dashboard).Methods("GET")
+	r.HandleFunc("/events", DashboardEvents).Methods("GET")
+	r.HandleFunc("/admin/reload", Reload).Methods("POST")
+	r.HandleFunc("/deliveries", ListDeliveries).Methods("GET")
+	r.HandleFunc("/deliveries/{id:[0-9]+}", GetDelivery).Methods("GET")
+	r.HandleFunc("/deliveries/{id:[0-9]+}/redeliver", Redeliver).Methods("POST")
 	r.HandleFunc("/{project}", WebHookListener).Methods("POST")
 	r.HandleFunc("/{project}/", WebHookListener).Methods("POST")
-	r.HandleFunc("/{project}/status", BuildStatus).Methods("GET")
-	r.HandleFunc("/{project}/status/", BuildStatus).Methods("GET")
-	r.HandleFunc("/{project}/livestatus", LiveStatusUpdate)
-	r.HandleFunc("/{project}/livestatus/", LiveStatusUpdate)
+	r.HandleFunc("/{project}/status", RequireAuth(core.ACCESS_READ, BuildStatus)).Methods("GET")
+	r.HandleFunc("/{project}/status/", RequireAuth(core.ACCESS_READ, BuildStatus)).Methods("GET")
+	r.HandleFunc("/{project}/pipelines", RequireAuth(core.ACCESS_READ, PipelinesStatus)).Methods("GET")
+	r.HandleFunc("/{project}/share", RequireAuth(core.ACCESS_CONTROL, ShareLink)).Methods("POST")
+	r.HandleFunc("/{project}/trigger", TriggerBuild).Methods("POST")
+	r.HandleFunc("/{project}/badge.svg", Badge).Methods("GET")
+	r.HandleFunc("/{project}/builds/{id:[0-9]+}/wait", RequireAuth(core.ACCESS_READ, WaitBuild)).Methods("GET")
+	r.HandleFunc("/{project}/pulls/{number:[0-9]+}/status", RequireAuth(core.ACCESS_READ, PullRequestStatus)).Methods("GET")
+	r.HandleFunc("/{project}/livestatus", RequireAuth(core.ACCESS_READ, LiveStatusUpdate))
+	r.HandleFunc("/{project}/livestatus/", RequireAuth(core.ACCESS_READ, LiveStatusUpdate))
+	r.HandleFunc("/{project}/events", RequireAuth(core.ACCESS_READ, LiveEvents)).Methods("GET")
 }
@@ -0,0 +1,65 @@
+package httpinterface
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// serverInit - starts core with top level keys of global and a single project p building in a temporary directory
+func serverInit(t *testing.T, global string) {
+	t.Helper()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	config := global + "\n[project.p]\nbranch = \"main\"\nsecret = \"x\"\ncwd = '" + dir + "'\nsteps = [[\"true\"]]\n"
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := core.ServerInit(file, log.New(io.Discard, "", 0), &wg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		core.DrainAll()
+		wg.Wait()
+	})
+}
+
+func TestBuildStatusSkipBeforeFirstBuild(t *testing.T) {
+	serverInit(t, "")
+
+	status := func() (int, map[string]any) {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/p/status?format=json", nil), map[string]string{"project": "p"})
+		w := httptest.NewRecorder()
+		BuildStatus(w, r)
+		var body map[string]any
+		json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+
+	if code, _ := status(); code != 400 {
+		t.Errorf("status without builds or skips = %d, want 400", code)
+	}
+
+	core.ResultMap.Mu.Lock()
+	core.ResultMap.Skips["p"] = core.SkipRecord{Time: time.Now().UTC(), Commit: "abc", Reason: "head commit message contains [skip ci]"}
+	core.ResultMap.Mu.Unlock()
+
+	code, body := status()
+	skip, _ := body["lastSkip"].(map[string]any)
+	if code != 200 || skip == nil {
+		t.Fatalf("status with skip = %d %v, want 200 with lastSkip", code, body)
+	}
+	if skip["commit"] != "abc" {
+		t.Errorf("lastSkip = %v, want commit abc", skip)
+	}
+}
@@ -0,0 +1,446 @@
+package httpinterface
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// versioned json api, everything under API_V1_PREFIX keeps its shape within the version
+
+const API_V1_PREFIX = "/api/v1"
+
+const (
+	DEFAULT_PER_PAGE = 20
+	MAX_PER_PAGE     = 100
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+type APIErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+type APIPage struct {
+	Items   any `json:"items"`
+	Page    int `json:"page"`
+	PerPage int `json:"perPage"`
+	Total   int `json:"total"`
+}
+
+type APIQueue struct {
+	Project  string `json:"project"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Running  *int64 `json:"running"`
+}
+
+type APIStep struct {
+	Index       int      `json:"index"`
+	Command     []string `json:"command"`
+	Status      string   `json:"status"`
+	Description string   `json:"description"`
+	Duration    float64  `json:"duration"`
+	LogURL      string   `json:"logUrl"`
+}
+
+type APIBuild struct {
+	ID         int64        `json:"id"`
+	Project    string       `json:"project"`
+	Pipeline   string       `json:"pipeline"`
+	Status     string       `json:"status"`
+	Trigger    core.Trigger `json:"trigger"`
+	StartedAt  *time.Time   `json:"startedAt"`
+	FinishedAt *time.Time   `json:"finishedAt"`
+	URL        string       `json:"url"`
+	Steps      []APIStep    `json:"steps,omitempty"`
+	// steps read from pipeline file of repository, they are listed in steps too
+	Rendered *core.RenderedPipeline `json:"rendered,omitempty"`
+}
+
+type APIPipeline struct {
+	Name      string    `json:"name"`
+	On        []string  `json:"on"`
+	Branches  []string  `json:"branches"`
+	Tags      []string  `json:"tags"`
+	Schedule  string    `json:"schedule"`
+	Steps     int       `json:"steps"`
+	LastBuild *APIBuild `json:"lastBuild"`
+}
+
+type APIProject struct {
+	Name      string        `json:"name"`
+	Branch    string        `json:"branch"`
+	Pipelines []APIPipeline `json:"pipelines"`
+	Queue     APIQueue      `json:"queue"`
+	URL       string        `json:"url"`
+}
+
+// RespondAPIError - responds with the error object that every api v1 endpoint uses
+func RespondAPIError(w http.ResponseWriter, statusCode int, code string, message string) {
+	Respond(w, statusCode, APIError{
+		Error: APIErrorBody{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// Negotiate - returns the first of offered content types that is acceptable according to Accept header,
+// empty string when none is. offers are in order of server preference, q values only rule types out (q=0)
+func Negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	for _, offer := range offers {
+		for _, part := range strings.Split(accept, ",") {
+			params := strings.Split(part, ";")
+			mediaRange := strings.TrimSpace(params[0])
+			if acceptRejected(params[1:]) {
+				continue
+			}
+			if mediaRange == "*/*" || mediaRange == offer ||
+				(strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*"))) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+func acceptRejected(params []string) bool {
+	for _, param := range params {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(value, 64)
+		return err == nil && q == 0
+	}
+	return false
+}
+
+// apiJSON - wraps api v1 handlers that only produce json
+func apiJSON(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if Negotiate(r, "application/json") == "" {
+			RespondAPIError(w, http.StatusNotAcceptable, "not_acceptable", "only application/json can be produced")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// pagination - returns page and perPage asked for with ?page= and ?perPage=, page starts at 1
+func pagination(r *http.Request) (int, int, bool) {
+	page, perPage := 1, DEFAULT_PER_PAGE
+	var err error
+	if p := r.URL.Query().Get("page"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			return 0, 0, false
+		}
+	}
+	if p := r.URL.Query().Get("perPage"); p != "" {
+		if perPage, err = strconv.Atoi(p); err != nil || perPage < 1 {
+			return 0, 0, false
+		}
+	}
+	if perPage > MAX_PER_PAGE {
+		perPage = MAX_PER_PAGE
+	}
+	return page, perPage, true
+}
+
+func paginate[T any](items []T, page int, perPage int) APIPage {
+	start := (page - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	return APIPage{
+		Items:   items[start:end],
+		Page:    page,
+		PerPage: perPage,
+		Total:   len(items),
+	}
+}
+
+func stepStatus(state core.JobState, i int) string {
+	switch {
+	case i < len(state.StepResults) && state.StepResults[i].Error != nil:
+		return core.FAILED
+	case i < len(state.StepResults):
+		return core.SUCCESS
+	case state.BuildStatus == core.FAILED:
+		return "skipped"
+	default:
+		return core.PENDING
+	}
+}
+
+func toAPIBuild(project string, state core.JobState, withSteps bool) APIBuild {
+	build := APIBuild{
+		ID:       state.ID,
+		Project:  project,
+		Pipeline: state.Pipeline,
+		Status:   state.BuildStatus,
+		Trigger:  state.Trigger,
+		URL:      BasePath() + API_V1_PREFIX + "/builds/" + strconv.FormatInt(state.ID, 10),
+	}
+	if !state.LastBuildStart.IsZero() {
+		startedAt := state.LastBuildStart
+		build.StartedAt = &startedAt
+	}
+	if !state.FinishedAt.IsZero() {
+		finishedAt := state.FinishedAt
+		build.FinishedAt = &finishedAt
+	}
+	if !withSteps {
+		return build
+	}
+	build.Rendered = state.Rendered
+	build.Steps = make([]APIStep, 0, len(state.Steps))
+	for i, command := range state.Steps {
+		step := APIStep{
+			Index:   i,
+			Command: command,
+			Status:  stepStatus(state, i),
+			LogURL:  build.URL + "/steps/" + strconv.Itoa(i) + "/log",
+		}
+		if i < len(state.StepResults) {
+			step.Description = state.StepResults[i].Description
+			step.Duration = state.StepResults[i].Duration
+		}
+		build.Steps = append(build.Steps, step)
+	}
+	return build
+}
+
+func toAPIQueue(projectName string) APIQueue {
+	queue := APIQueue{Project: projectName}
+	if jq, ok := core.ProjectQueue(projectName); ok {
+		queue.Depth = jq.Len()
+		queue.Capacity = jq.Cap()
+	}
+	if running, ok := core.History.Running(projectName); ok {
+		queue.Running = &running.ID
+	}
+	return queue
+}
+
+func toAPIProject(projectName string, project core.Project) APIProject {
+	res := APIProject{
+		Name:      projectName,
+		Branch:    project.Branch,
+		Pipelines: make([]APIPipeline, 0),
+		Queue:     toAPIQueue(projectName),
+		URL:       BasePath() + API_V1_PREFIX + "/projects/" + projectName,
+	}
+	pipelines := project.Pipelines()
+	for _, name := range project.PipelineNames() {
+		pipeline := pipelines[name]
+		p := APIPipeline{
+			Name:     name,
+			On:       pipeline.On,
+			Branches: pipeline.Branches,
+			Tags:     pipeline.Tags,
+			Schedule: pipeline.Schedule,
+			Steps:    len(pipeline.Steps),
+		}
+		if state, ok := core.ResultMap.State(projectName, name); ok {
+			lastBuild := toAPIBuild(projectName, state, false)
+			p.LastBuild = &lastBuild
+		}
+		res.Pipelines = append(res.Pipelines, p)
+	}
+	return res
+}
+
+func sortedProjectNames() []string {
+	conf := core.Conf()
+	names := make([]string, 0, len(conf.Project))
+	for name := range conf.Project {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// authorizedProjectNames - sorted names of projects the request is authorized to read
+func authorizedProjectNames(r *http.Request) []string {
+	names := make([]string, 0)
+	for _, name := range sortedProjectNames() {
+		if authorized(r, name, core.ACCESS_READ) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func APIListProjects(w http.ResponseWriter, r *http.Request) {
+	if !apiAuthorize(w, r, "", core.ACCESS_READ) {
+		return
+	}
+	page, perPage, ok := pagination(r)
+	if !ok {
+		RespondAPIError(w, 400, "invalid_pagination", "page and perPage should be positive numbers")
+		return
+	}
+	conf := core.Conf()
+	projects := make([]APIProject, 0)
+	for _, name := range authorizedProjectNames(r) {
+		if project, ok := conf.Project[name]; ok {
+			projects = append(projects, toAPIProject(name, project))
+		}
+	}
+	Respond(w, 200, paginate(projects, page, perPage))
+}
+
+func APIGetProject(w http.ResponseWriter, r *http.Request) {
+	projectName := mux.Vars(r)["project"]
+	project, ok := core.Conf().Project[projectName]
+	if !ok {
+		RespondAPIError(w, 404, "project_not_found", "no project found with given project name")
+		return
+	}
+	Respond(w, 200, toAPIProject(projectName, project))
+}
+
+// APIListBuilds - builds of project newest first, ?pipeline= filters by pipeline
+func APIListBuilds(w http.ResponseWriter, r *http.Request) {
+	projectName := mux.Vars(r)["project"]
+	if _, ok := core.Conf().Project[projectName]; !ok {
+		RespondAPIError(w, 404, "project_not_found", "no project found with given project name")
+		return
+	}
+	page, perPage, ok := pagination(r)
+	if !ok {
+		RespondAPIError(w, 400, "invalid_pagination", "page and perPage should be positive numbers")
+		return
+	}
+	pipeline := r.URL.Query().Get("pipeline")
+	builds := make([]APIBuild, 0)
+	for _, state := range core.History.List(projectName) {
+		if pipeline != "" && state.Pipeline != pipeline {
+			continue
+		}
+		builds = append(builds, toAPIBuild(projectName, state, false))
+	}
+	Respond(w, 200, paginate(builds, page, perPage))
+}
+
+func buildFromVars(w http.ResponseWriter, r *http.Request) (string, core.JobState, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondAPIError(w, 400, "invalid_build_id", "build id should be a number")
+		return "", core.JobState{}, false
+	}
+	project, state, ok := core.History.Get(id)
+	if !ok {
+		RespondAPIError(w, 404, "build_not_found", "no build found with given id, it may have expired")
+		return "", core.JobState{}, false
+	}
+	if !apiAuthorize(w, r, project, core.ACCESS_READ) {
+		return "", core.JobState{}, false
+	}
+	return project, state, true
+}
+
+func APIGetBuild(w http.ResponseWriter, r *http.Request) {
+	project, state, ok := buildFromVars(w, r)
+	if !ok {
+		return
+	}
+	Respond(w, 200, toAPIBuild(project, state, true))
+}
+
+// APIStepLog - output of a step, plain text unless json is preferred by Accept header
+func APIStepLog(w http.ResponseWriter, r *http.Request) {
+	contentType := Negotiate(r, "text/plain", "application/json")
+	if contentType == "" {
+		RespondAPIError(w, http.StatusNotAcceptable, "not_acceptable", "only text/plain and application/json can be produced")
+		return
+	}
+	_, state, ok := buildFromVars(w, r)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(mux.Vars(r)["step"])
+	if err != nil || n < 0 || n >= len(state.Steps) {
+		RespondAPIError(w, 404, "step_not_found", "build has no step with given index")
+		return
+	}
+	if n >= len(state.StepResults) {
+		RespondAPIError(w, 404, "log_not_found", "step has not run yet")
+		return
+	}
+
+	result := state.StepResults[n]
+	if contentType == "application/json" {
+		res := map[string]any{
+			"index":       n,
+			"command":     state.Steps[n],
+			"status":      stepStatus(state, n),
+			"output":      result.Output,
+			"description": result.Description,
+			"duration":    result.Duration,
+		}
+		Respond(w, 200, res)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(result.Output))
+}
+
+func APIQueues(w http.ResponseWriter, r *http.Request) {
+	if !apiAuthorize(w, r, "", core.ACCESS_READ) {
+		return
+	}
+	queues := make([]APIQueue, 0)
+	for _, name := range authorizedProjectNames(r) {
+		queues = append(queues, toAPIQueue(name))
+	}
+	Respond(w, 200, queues)
+}
+
+func APIOpenAPI(w http.ResponseWriter, r *http.Request) {
+	document := openAPIDocument
+	if BasePath() != "" {
+		document = bytes.Replace(document, []byte(`"url": "`+API_V1_PREFIX+`"`), []byte(`"url": "`+BasePath()+API_V1_PREFIX+`"`), 1)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(document)
+}
+
+func APIRouterInit(r *mux.Router) {
+	api := r.PathPrefix(API_V1_PREFIX).Subrouter()
+	api.HandleFunc("/openapi.json", apiJSON(APIOpenAPI)).Methods("GET")
+	api.HandleFunc("/projects", apiJSON(APIListProjects)).Methods("GET")
+	api.HandleFunc("/projects/{project}", apiJSON(apiRequireAuth(core.ACCESS_READ, APIGetProject))).Methods("GET")
+	api.HandleFunc("/projects/{project}/builds", apiJSON(apiRequireAuth(core.ACCESS_READ, APIListBuilds))).Methods("GET")
+	api.HandleFunc("/builds/{id}", apiJSON(APIGetBuild)).Methods("GET")
+	api.HandleFunc("/builds/{id}/steps/{step}/log", APIStepLog).Methods("GET")
+	api.HandleFunc("/queue", apiJSON(APIQueues)).Methods("GET")
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondAPIError(w, 404, "not_found", "no such endpoint")
+	})
+}
@@ -0,0 +1,180 @@
+package httpinterface
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// share links are valid for a day unless ?ttl= asks otherwise, never longer than MAX_SHARE_TTL
+const (
+	DEFAULT_SHARE_TTL = 24 * time.Hour
+	MAX_SHARE_TTL     = 30 * 24 * time.Hour
+)
+
+// authorized - checks credentials of request against auth of project and global auth, empty project
+// only checks global auth. requests pass when no auth is configured. browsers send cached basic
+// credentials along with forms of other sites, so basic auth of cross site requests gets no control access
+func authorized(r *http.Request, projectName string, access string) bool {
+	conf := core.Conf()
+	auths := []core.AuthConf{conf.Auth}
+	if projectName != "" {
+		if project, ok := conf.Project[projectName]; ok && project.Auth != nil {
+			auths = append(auths, *project.Auth)
+		}
+	}
+	enabled := false
+	for _, auth := range auths {
+		enabled = enabled || auth.Enabled()
+	}
+	if !enabled {
+		return true
+	}
+
+	user, password, hasBasic := r.BasicAuth()
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	hasBearer := strings.EqualFold(scheme, "bearer") && token != ""
+	for _, auth := range auths {
+		if hasBasic && !(access == core.ACCESS_CONTROL && crossSite(r)) && auth.CheckPassword(user, password, access) {
+			return true
+		}
+		if hasBearer && auth.CheckToken(token, access) {
+			return true
+		}
+	}
+
+	share := r.URL.Query().Get("share")
+	return share != "" && projectName != "" && access == core.ACCESS_READ &&
+		core.ProjectAuth(projectName).CheckShare(projectName, share, time.Now())
+}
+
+func challenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="ghhooks", charset="UTF-8"`)
+	w.Header().Add("WWW-Authenticate", `Bearer realm="ghhooks"`)
+}
+
+// Authorize - responds with 401 when request is not authorized to access project
+func Authorize(w http.ResponseWriter, r *http.Request, projectName string, access string) bool {
+	if authorized(r, projectName, access) {
+		return true
+	}
+	challenge(w)
+	Respond(w, 401, map[string]interface{}{
+		"error": "authentication required",
+	})
+	return false
+}
+
+// apiAuthorize - Authorize with api v1 error object
+func apiAuthorize(w http.ResponseWriter, r *http.Request, projectName string, access string) bool {
+	if authorized(r, projectName, access) {
+		return true
+	}
+	challenge(w)
+	RespondAPIError(w, 401, "unauthorized", "authentication required")
+	return false
+}
+
+// RequireAuth - wraps handlers of routes with a {project} var
+func RequireAuth(access string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !Authorize(w, r, mux.Vars(r)["project"], access) {
+			return
+		}
+		h(w, r)
+	}
+}
+
+// apiRequireAuth - RequireAuth for api v1 routes
+func apiRequireAuth(access string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthorize(w, r, mux.Vars(r)["project"], access) {
+			return
+		}
+		h(w, r)
+	}
+}
+
+// shareQuery - query string that carries share token of request over to links of a page
+func shareQuery(r *http.Request) string {
+	if share := r.URL.Query().Get("share"); share != "" {
+		return "&share=" + url.QueryEscape(share)
+	}
+	return ""
+}
+
+// CheckOrigin - live status websockets are accepted from the server itself, from allowedOrigins and from
+// clients that send no Origin (non browser clients)
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range core.Conf().Auth.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// crossSite - request comes from a page of another origin than the server and allowedOrigins
+func crossSite(r *http.Request) bool {
+	if r.Header.Get("Origin") != "" {
+		return !CheckOrigin(r)
+	}
+	return r.Header.Get("Sec-Fetch-Site") == "cross-site"
+}
+
+// ShareLink - creates a signed link to status page of project that gives read access until it expires
+func ShareLink(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	if _, ok := core.Conf().Project[projectID]; !ok {
+		Respond(w, 404, map[string]interface{}{
+			"error": "no project found with given project name",
+		})
+		return
+	}
+	auth := core.ProjectAuth(projectID)
+	if auth.ShareSecret == "" {
+		Respond(w, 400, map[string]interface{}{
+			"error": "share links are not configured, set shareSecret",
+		})
+		return
+	}
+
+	ttl := DEFAULT_SHARE_TTL
+	if t := r.URL.Query().Get("ttl"); t != "" {
+		seconds, err := strconv.Atoi(t)
+		if err != nil || seconds <= 0 {
+			Respond(w, 400, map[string]interface{}{
+				"error": "invalid ttl, expected seconds",
+			})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl > MAX_SHARE_TTL {
+		ttl = MAX_SHARE_TTL
+	}
+
+	expires := time.Now().Add(ttl).UTC()
+	share := auth.SignShare(projectID, expires)
+	Respond(w, 201, map[string]interface{}{
+		"url":     RequestScheme(r) + "://" + r.Host + BasePath() + "/" + projectID + "/status?share=" + url.QueryEscape(share),
+		"share":   share,
+		"expires": expires,
+	})
+}
@@ -0,0 +1,59 @@
+package httpinterface
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"ghhooks.com/hook/core"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthorizedCrossSite(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverInit(t, "[auth]\nusers = { admin = '"+string(hash)+"' }\ntokens = [\"tok\"]\nallowedOrigins = [\"https://dashboard.example.com\"]\n")
+
+	tests := []struct {
+		name    string
+		access  string
+		bearer  bool
+		headers map[string]string
+		want    bool
+	}{
+		{name: "no origin", access: core.ACCESS_CONTROL, want: true},
+		{name: "same origin", access: core.ACCESS_CONTROL, headers: map[string]string{"Origin": "http://hooks.example.com"}, want: true},
+		{name: "allowed origin", access: core.ACCESS_CONTROL, headers: map[string]string{"Origin": "https://dashboard.example.com"}, want: true},
+		{name: "foreign origin", access: core.ACCESS_CONTROL, headers: map[string]string{"Origin": "https://evil.example.net"}},
+		{name: "cross site without origin", access: core.ACCESS_CONTROL, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}},
+		{name: "same site fetch", access: core.ACCESS_CONTROL, headers: map[string]string{"Sec-Fetch-Site": "same-origin"}, want: true},
+		{name: "foreign origin can read", access: core.ACCESS_READ, headers: map[string]string{"Origin": "https://evil.example.net"}, want: true},
+		{name: "bearer token from foreign origin", access: core.ACCESS_CONTROL, bearer: true, headers: map[string]string{"Origin": "https://evil.example.net"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://hooks.example.com/p/trigger", nil)
+			if tt.bearer {
+				r.Header.Set("Authorization", "Bearer tok")
+			} else {
+				r.SetBasicAuth("admin", "pw")
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := authorized(r, "p", tt.access); got != tt.want {
+				t.Errorf("authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// rejected like any other unauthenticated request
+	r := httptest.NewRequest("POST", "http://hooks.example.com/admin/reload", nil)
+	r.SetBasicAuth("admin", "pw")
+	r.Header.Set("Origin", "https://evil.example.net")
+	w := httptest.NewRecorder()
+	if Authorize(w, r, "", core.ACCESS_CONTROL) || w.Code != 401 {
+		t.Errorf("Authorize() of cross site reload = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package httpinterface
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// badge colors by build status, same palette shields.io uses
+var badgeColors = map[string]string{
+	core.SUCCESS: "#4c1",
+	core.FAILED:  "#e05d44",
+	core.PENDING: "#dfb317",
+	core.QUEUED:  "#007ec6",
+}
+
+const badgeUnknownColor = "#9f9f9f"
+
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[3]s: %[4]s">
+<title>%[3]s: %[4]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[6]d" height="20" fill="%[5]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[3]s</text><text x="%[7]d" y="14">%[3]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[8]d" y="14">%[4]s</text>
+</g>
+</svg>
+`
+
+// RenderBadge - shields style flat badge, text width is estimated since fonts are not available to measure
+func RenderBadge(label string, message string, color string) string {
+	labelWidth := len([]rune(label))*7 + 10
+	messageWidth := len([]rune(message))*7 + 10
+	return fmt.Sprintf(badgeTemplate,
+		labelWidth+messageWidth,
+		labelWidth,
+		html.EscapeString(label),
+		html.EscapeString(message),
+		color,
+		messageWidth,
+		labelWidth/2,
+		labelWidth+messageWidth/2,
+	)
+}
+
+// Badge - build status badge of project pipeline, it only reports the status so it never needs auth
+func Badge(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	project, ok := core.Conf().Project[projectID]
+	if !ok {
+		Respond(w, 404, map[string]interface{}{
+			"error": "no project found with given project name",
+		})
+		return
+	}
+	pipelineName, _, ok := PipelineFromQuery(r, project)
+	if !ok {
+		Respond(w, 404, map[string]interface{}{
+			"error": "no pipeline found with given pipeline name",
+		})
+		return
+	}
+
+	label := projectID
+	if r.URL.Query().Get("pipeline") != "" {
+		label = projectID + " " + pipelineName
+	}
+	message, color := "unknown", badgeUnknownColor
+	if state, ok := core.ResultMap.State(projectID, pipelineName); ok {
+		message = state.BuildStatus
+		if c, ok := badgeColors[state.BuildStatus]; ok {
+			color = c
+		}
+	}
+
+	// badges are proxied and cached by github, ask them to revalidate every time
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	w.WriteHeader(200)
+	w.Write([]byte(RenderBadge(label, message, color)))
+}
@@ -0,0 +1,155 @@
+package httpinterface
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// waiting for a build is capped so that a stuck build can not hold connections forever
+const (
+	DEFAULT_WAIT_TIMEOUT = 10 * time.Minute
+	MAX_WAIT_TIMEOUT     = time.Hour
+)
+
+// WaitTimeout - returns timeout asked for with ?timeout= (seconds), default is 10 minutes
+func WaitTimeout(r *http.Request) (time.Duration, error) {
+	timeout := DEFAULT_WAIT_TIMEOUT
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		seconds, err := strconv.Atoi(t)
+		if err != nil {
+			return 0, err
+		}
+		if seconds <= 0 {
+			return 0, fmt.Errorf("timeout should be more than 0 seconds")
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout > MAX_WAIT_TIMEOUT {
+		timeout = MAX_WAIT_TIMEOUT
+	}
+	return timeout, nil
+}
+
+// WaitStatusCode - 200 when build succeeded, 422 when it failed and 504 when it has not finished yet
+func WaitStatusCode(state core.JobState) int {
+	switch state.BuildStatus {
+	case core.SUCCESS:
+		return 200
+	case core.FAILED:
+		return 422
+	default:
+		return 504
+	}
+}
+
+// WaitForBuilds - blocks until all builds have finished or timeout is over, returns final states of the builds
+// and the status code reflecting the worst of them
+func WaitForBuilds(ctx context.Context, timeout time.Duration, ids []int64) ([]core.JobState, int) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	statusCode := 200
+	states := make([]core.JobState, 0, len(ids))
+	for _, id := range ids {
+		state, err := core.History.Wait(ctx, id)
+		if err == core.ErrBuildNotFound {
+			continue
+		}
+		states = append(states, state)
+		if code := WaitStatusCode(state); code > statusCode {
+			statusCode = code
+		}
+	}
+	return states, statusCode
+}
+
+// WaitBuild - long polls build until it has finished, ?timeout= sets how long to wait in seconds
+func WaitBuild(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["project"]
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		Respond(w, 400, map[string]interface{}{
+			"error": "invalid build id",
+		})
+		return
+	}
+	timeout, err := WaitTimeout(r)
+	if err != nil {
+		Respond(w, 400, map[string]interface{}{
+			"error": "invalid timeout, expected seconds",
+		})
+		return
+	}
+
+	project, _, ok := core.History.Get(id)
+	if !ok || project != projectID {
+		Respond(w, 404, map[string]interface{}{
+			"error": core.ErrBuildNotFound.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	state, err := core.History.Wait(ctx, id)
+	if err == core.ErrBuildNotFound {
+		Respond(w, 404, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	Respond(w, WaitStatusCode(state), state)
+}
+
+// TriggerBuild - queues a build of project pipeline (?pipeline=, default pipeline when not set) on
+// project branch, whatever events the pipeline runs on. needs auth to be configured since it skips
+// webhook signatures, ?wait=true responds once the build has finished
+func TriggerBuild(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	project, ok := core.Conf().Project[projectID]
+	if !ok {
+		Respond(w, 404, map[string]interface{}{
+			"error": "no project found with given project name",
+		})
+		return
+	}
+	if !core.ProjectAuth(projectID).Enabled() && !core.Conf().Auth.Enabled() {
+		Respond(w, 403, map[string]interface{}{
+			"error": "trigger endpoint needs [auth] users or tokens to be configured",
+		})
+		return
+	}
+	if !Authorize(w, r, projectID, core.ACCESS_CONTROL) {
+		return
+	}
+	pipelineName, _, ok := PipelineFromQuery(r, project)
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no pipeline found with given pipeline name",
+		})
+		return
+	}
+
+	trigger := core.Trigger{Event: core.EVENT_MANUAL}
+	if project.Branch != "" {
+		trigger.Ref = "refs/heads/" + project.Branch
+	}
+	build := core.NewBuild(projectID, project, pipelineName, trigger)
+	if !core.Enqueue(build) {
+		Respond(w, 429, map[string]interface{}{
+			"error": "build queue is full",
+		})
+		return
+	}
+	RespondOrWait(w, r, 201, map[string]any{
+		"message":  "build queued successfully",
+		"buildIds": []int64{build.ID},
+	}, []int64{build.ID})
+}
@@ -0,0 +1,109 @@
+package httpinterface
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ghhooks.com/hook/core"
+)
+
+type DashboardProject struct {
+	Name       string       `json:"name"`
+	Pipeline   string       `json:"pipeline"`
+	BuildID    int64        `json:"buildId"`
+	Status     string       `json:"status"`
+	StartedAt  *time.Time   `json:"startedAt"`
+	Duration   float64      `json:"duration"`
+	Trigger    core.Trigger `json:"trigger"`
+	QueueDepth int          `json:"queueDepth"`
+	URL        string       `json:"url"`
+}
+
+type DashboardResponse struct {
+	Projects    []DashboardProject `json:"projects"`
+	EventsRoute template.URL       `json:"eventsRoute"`
+}
+
+// NewDashboardProject - last started build and queue depth of project, builds that are still queued are
+// only counted in queue depth
+func NewDashboardProject(projectName string) DashboardProject {
+	res := DashboardProject{
+		Name: projectName,
+		URL:  BasePath() + "/" + projectName + "/status",
+	}
+	if jq, ok := core.ProjectQueue(projectName); ok {
+		res.QueueDepth = jq.Len()
+	}
+	for _, state := range core.History.List(projectName) {
+		if state.BuildStatus == core.QUEUED {
+			continue
+		}
+		startedAt := state.LastBuildStart
+		res.Pipeline = state.Pipeline
+		res.BuildID = state.ID
+		res.Status = state.BuildStatus
+		res.StartedAt = &startedAt
+		res.Trigger = state.Trigger
+		res.URL += "?pipeline=" + url.QueryEscape(state.Pipeline)
+		if state.Finished() {
+			res.Duration = state.FinishedAt.Sub(startedAt).Seconds()
+		} else {
+			res.Duration = time.Since(startedAt).Seconds()
+		}
+		break
+	}
+	return res
+}
+
+// Dashboard - every project the request is authorized for along with its last build
+func Dashboard(w http.ResponseWriter, r *http.Request) {
+	if !Authorize(w, r, "", core.ACCESS_READ) {
+		return
+	}
+	projects := make([]DashboardProject, 0)
+	for _, name := range authorizedProjectNames(r) {
+		projects = append(projects, NewDashboardProject(name))
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		Respond(w, 200, projects)
+		return
+	}
+
+	renderTemplate(w, "dashboard.html", DashboardResponse{
+		Projects:    projects,
+		EventsRoute: template.URL(BasePath() + "/events"),
+	})
+}
+
+// DashboardEvents - server sent events with the dashboard row of a project whenever one of its builds
+// is queued or changes state, one stream covers every project the request is authorized for
+func DashboardEvents(w http.ResponseWriter, r *http.Request) {
+	if !Authorize(w, r, "", core.ACCESS_READ) {
+		return
+	}
+	streamEvents(w, r, core.DashboardFeed, latestPerProject, func(event core.LiveEvent) (any, bool) {
+		if !authorized(r, event.Project, core.ACCESS_READ) {
+			return nil, false
+		}
+		return NewDashboardProject(event.Project), true
+	})
+}
+
+// latestPerProject - rows are built from current state, replaying the backlog only needs the latest
+// event of a project
+func latestPerProject(backlog []core.LiveEvent) []core.LiveEvent {
+	latest := make(map[string]int64)
+	for _, event := range backlog {
+		latest[event.Project] = event.ID
+	}
+	events := make([]core.LiveEvent, 0, len(latest))
+	for _, event := range backlog {
+		if latest[event.Project] == event.ID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
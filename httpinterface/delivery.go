@@ -0,0 +1,205 @@
+package httpinterface
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// ProcessDelivery - verifies delivery and enqueues the build, delivery is updated with the signature verdict,
+// the decision taken and the id of the build. it returns status code and body of the response
+func ProcessDelivery(delivery *core.Delivery) (int, map[string]any) {
+	statusCode, res := processDelivery(delivery)
+	delivery.StatusCode = statusCode
+	if delivery.Verdict == "" {
+		delivery.Verdict = core.VERDICT_REJECTED
+		if msg, ok := res["error"]; ok {
+			delivery.Decision = fmt.Sprintf("rejected: %v", msg)
+		}
+	}
+
+	deliveriesTotal.Inc(eventLabel(delivery.Event), delivery.Verdict)
+	if delivery.Signature == core.SIGNATURE_FAILED {
+		signatureFailures.Inc(delivery.Project)
+	}
+	return statusCode, res
+}
+
+func processDelivery(delivery *core.Delivery) (int, map[string]any) {
+	projectID := delivery.Project
+	project, ok := core.Conf().Project[projectID]
+	if !ok {
+		return 400, map[string]any{
+			"error": "no project found with given project name",
+		}
+	}
+
+	bodyInBytes := []byte(delivery.Body)
+	eventType := delivery.Event
+
+	delivery.Signature = core.SIGNATURE_MISSING
+	hash := delivery.Headers.Get("X-Hub-Signature-256")
+	if hash == "" && eventType == "ping" && project.Secret != "" {
+		return 412, map[string]any{
+			"error": "secret is configured but ping event is not signed",
+		}
+	}
+	if hash != "" {
+		verified, err := VerifySignature(bodyInBytes, hash, project.Secret)
+		if err != nil {
+			delivery.Signature = core.SIGNATURE_FAILED
+			return 500, map[string]any{
+				"error": err.Error(),
+			}
+		}
+		if !verified {
+			delivery.Signature = core.SIGNATURE_FAILED
+			return 412, map[string]any{
+				"error": "signauture could not be verified",
+			}
+		}
+		delivery.Signature = core.SIGNATURE_VERIFIED
+	}
+
+	if eventType == "ping" {
+		warnings, err := VerifyPing(bodyInBytes)
+		if err != nil {
+			return 400, map[string]any{
+				"error": fmt.Sprintf("error: %v.", err),
+			}
+		}
+		delivery.Verdict = core.VERDICT_PONG
+		delivery.Decision = "pong"
+		return 200, map[string]any{
+			"message":  "pong",
+			"warnings": warnings,
+		}
+	}
+
+	trigger, pipelines, err := VerifyEvent(eventType, bodyInBytes, project)
+
+	var skipErr *SkipError
+	if errors.As(err, &skipErr) {
+		core.ResultMap.Mu.Lock()
+		core.ResultMap.Skips[projectID] = core.SkipRecord{
+			Time:   time.Now().UTC(),
+			Commit: skipErr.Commit,
+			Reason: skipErr.Reason,
+		}
+		core.ResultMap.Mu.Unlock()
+		delivery.Verdict = core.VERDICT_SKIPPED
+		delivery.Decision = "skipped: " + skipErr.Reason
+		return 200, map[string]any{
+			"message": "build skipped",
+			"reason":  skipErr.Reason,
+		}
+	}
+
+	if err != nil {
+		return 400, map[string]any{
+			"error": fmt.Sprintf("error: %v.", err),
+		}
+	}
+
+	// enqueing a job for every pipeline that the event is routed to
+	queued, full := make([]string, 0), make([]string, 0)
+	for _, pipeline := range pipelines {
+		build := core.NewBuild(projectID, project, pipeline, trigger)
+		if !core.Enqueue(build) {
+			full = append(full, pipeline)
+			continue
+		}
+		queued = append(queued, pipeline)
+		delivery.BuildIDs = append(delivery.BuildIDs, build.ID)
+	}
+	if len(queued) == 0 {
+		delivery.Verdict = core.VERDICT_QUEUE_FULL
+		delivery.Decision = "queue full"
+		return 429, map[string]any{
+			"error":    "build queue is full",
+			"buildIds": delivery.BuildIDs,
+		}
+	}
+	delivery.Verdict = core.VERDICT_QUEUED
+	delivery.Decision = "queued: " + strings.Join(queued, ", ")
+	if len(full) > 0 {
+		// builds that were queued run, so the delivery is not to be retried
+		delivery.Decision += "; queue full: " + strings.Join(full, ", ")
+		return 201, map[string]any{
+			"message":   "some builds could not be queued, queue is full",
+			"buildIds":  delivery.BuildIDs,
+			"queueFull": full,
+		}
+	}
+	return 201, map[string]any{
+		"message":  "build queued successfully",
+		"buildIds": delivery.BuildIDs,
+	}
+}
+
+// ListDeliveries - deliveries of every project the request is authorized for, ?project= filters by project
+func ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	if !Authorize(w, r, project, core.ACCESS_READ) {
+		return
+	}
+	deliveries := make([]core.Delivery, 0)
+	for _, delivery := range core.Deliveries.List(project) {
+		if authorized(r, delivery.Project, core.ACCESS_READ) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	Respond(w, 200, deliveries)
+}
+
+func GetDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := deliveryFromVars(w, r)
+	if !ok || !Authorize(w, r, delivery.Project, core.ACCESS_READ) {
+		return
+	}
+	Respond(w, 200, delivery)
+}
+
+// Redeliver - runs a stored delivery again through verification and enqueueing, it is stored as new delivery
+func Redeliver(w http.ResponseWriter, r *http.Request) {
+	original, ok := deliveryFromVars(w, r)
+	if !ok || !Authorize(w, r, original.Project, core.ACCESS_CONTROL) {
+		return
+	}
+	delivery := core.Delivery{
+		GUID:         original.GUID,
+		Project:      original.Project,
+		Event:        original.Event,
+		ReceivedAt:   time.Now().UTC(),
+		Headers:      original.Headers,
+		Body:         original.Body,
+		RedeliveryOf: original.ID,
+	}
+	statusCode, res := ProcessDelivery(&delivery)
+	res["deliveryId"] = core.Deliveries.Add(delivery)
+	RespondOrWait(w, r, statusCode, res, delivery.BuildIDs)
+}
+
+func deliveryFromVars(w http.ResponseWriter, r *http.Request) (core.Delivery, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		Respond(w, 400, map[string]interface{}{
+			"error": "invalid delivery id",
+		})
+		return core.Delivery{}, false
+	}
+	delivery, ok := core.Deliveries.Get(id)
+	if !ok {
+		Respond(w, 404, map[string]interface{}{
+			"error": "no delivery found with given id, it may have expired",
+		})
+		return core.Delivery{}, false
+	}
+	return delivery, true
+}
@@ -0,0 +1,164 @@
+package httpinterface
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ghhooks.com/hook/core"
+	"github.com/gorilla/mux"
+)
+
+// comment sent on idle event streams so that proxies do not close them
+const SSE_KEEPALIVE = 15 * time.Second
+
+// NewWebsocketResponse - live status frame, sent over websocket and server sent events alike
+func NewWebsocketResponse(projectID string, state core.JobState) WebsocketResponse {
+	var successfullSteps int
+	for _, v := range state.StepResults {
+		if v.Error == nil {
+			successfullSteps = successfullSteps + 1
+		}
+	}
+
+	var coverage float64
+	if totalSteps := len(state.Steps); totalSteps > 0 {
+		coverage = float64(successfullSteps * 100 / totalSteps)
+	}
+
+	return WebsocketResponse{
+		JobState:    state,
+		ProjectName: projectID,
+		Coverage:    coverage,
+	}
+}
+
+// liveFeedFromRequest - returns live feed of project pipeline asked for, responds with error when there is none
+func liveFeedFromRequest(w http.ResponseWriter, r *http.Request) (string, *core.LiveFeed, bool) {
+	vars := mux.Vars(r)
+	projectID, ok := vars["project"]
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no vars found",
+		})
+		return "", nil, false
+	}
+
+	project, ok := core.Conf().Project[projectID]
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no project found with given project name",
+		})
+		return "", nil, false
+	}
+
+	pipelineName, pipeline, ok := PipelineFromQuery(r, project)
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no pipeline found with given pipeline name",
+		})
+		return "", nil, false
+	}
+	if len(pipeline.Steps) == 0 && project.PipelineFile == "" {
+		Respond(w, http.StatusBadRequest, map[string]interface{}{
+			"error": "no build steps configured",
+		})
+		return "", nil, false
+	}
+	feed, ok := core.PipelineFeed(projectID, pipelineName)
+	if !ok {
+		Respond(w, 400, map[string]interface{}{
+			"error": "no pipeline found with given pipeline name",
+		})
+		return "", nil, false
+	}
+	return projectID, feed, true
+}
+
+// LiveEvents - server sent events alternative to the live status websocket for clients behind proxies
+// that break websockets, clients resume from Last-Event-ID after reconnecting
+func LiveEvents(w http.ResponseWriter, r *http.Request) {
+	projectID, feed, ok := liveFeedFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	sseSubscribers.Inc(projectID)
+	defer sseSubscribers.Dec(projectID)
+
+	streamEvents(w, r, feed, nil, func(event core.LiveEvent) (any, bool) {
+		return NewWebsocketResponse(projectID, event.State), true
+	})
+}
+
+// streamEvents - serves events of feed as server sent events, resuming after Last-Event-ID, until the
+// client goes away. replay picks which backlog events are sent again (all when nil), data returns what
+// is sent for an event and false for events the client should not get
+func streamEvents(w http.ResponseWriter, r *http.Request, feed *core.LiveFeed,
+	replay func([]core.LiveEvent) []core.LiveEvent, data func(core.LiveEvent) (any, bool)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		Respond(w, 500, map[string]interface{}{
+			"error": "streaming is not supported",
+		})
+		return
+	}
+
+	var lastEventID int64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		lastEventID, _ = strconv.ParseInt(id, 10, 64)
+	}
+
+	updates, backlog, cancel := feed.Subscribe(lastEventID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	send := func(event core.LiveEvent) error {
+		v, ok := data(event)
+		if !ok {
+			return nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, b)
+		flusher.Flush()
+		return err
+	}
+
+	if replay != nil {
+		backlog = replay(backlog)
+	}
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+
+	keepalive := time.NewTicker(SSE_KEEPALIVE)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event := <-updates:
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+}
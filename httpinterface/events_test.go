@@ -0,0 +1,81 @@
+package httpinterface
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"ghhooks.com/hook/core"
+)
+
+// cancelAfter - recorder that cancels the request once body contains last, so streams end without a deadline
+type cancelAfter struct {
+	*httptest.ResponseRecorder
+	last   string
+	cancel context.CancelFunc
+}
+
+func (w cancelAfter) Write(b []byte) (int, error) {
+	n, err := w.ResponseRecorder.Write(b)
+	if strings.Contains(w.Body.String(), w.last) {
+		w.cancel()
+	}
+	return n, err
+}
+
+func TestStreamEvents(t *testing.T) {
+	data := func(event core.LiveEvent) (any, bool) {
+		// events of c are not sent
+		return event.State.ID, event.Project != "c"
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		replay      func([]core.LiveEvent) []core.LiveEvent
+		want        []string
+	}{
+		{name: "live only", want: []string{"id: 6\ndata: 6"}},
+		{name: "resumed", lastEventID: "1", want: []string{"id: 2\ndata: 2", "id: 3\ndata: 3", "id: 5\ndata: 5", "id: 6\ndata: 6"}},
+		{name: "latest per project", lastEventID: "1", replay: latestPerProject, want: []string{"id: 3\ndata: 3", "id: 5\ndata: 5", "id: 6\ndata: 6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := core.NewLiveFeed()
+			for i, project := range []string{"a", "b", "a", "c", "b"} {
+				feed.Publish(project, core.JobState{ID: int64(i + 1)})
+			}
+			go func() {
+				for feed.Subscribers() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				feed.Publish("a", core.JobState{ID: 6})
+			}()
+
+			// timeout only keeps a broken stream from hanging the test
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := cancelAfter{ResponseRecorder: httptest.NewRecorder(), last: "id: 6\n", cancel: cancel}
+			streamEvents(w, r, feed, tt.replay, data)
+			if ctx.Err() == context.DeadlineExceeded {
+				t.Fatal("stream did not send event 6")
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("content type = %s, want text/event-stream", ct)
+			}
+			got := regexp.MustCompile(`id: \d+\ndata: \d+`).FindAllString(w.Body.String(), -1)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
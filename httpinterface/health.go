@@ -0,0 +1,92 @@
+package httpinterface
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"ghhooks.com/hook/core"
+)
+
+// states of a project queue reported by readyz
+const (
+	QUEUE_ACCEPTING = "accepting"
+	QUEUE_FULL      = "full"
+	QUEUE_DRAINING  = "draining"
+)
+
+type RunningBuild struct {
+	ID        int64     `json:"id"`
+	Pipeline  string    `json:"pipeline"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+type QueueHealth struct {
+	Project  string        `json:"project"`
+	State    string        `json:"state"`
+	Depth    int           `json:"depth"`
+	Capacity int           `json:"capacity"`
+	Running  *RunningBuild `json:"running"`
+}
+
+type Readiness struct {
+	Status  string        `json:"status"`
+	Reasons []string      `json:"reasons"`
+	Queues  []QueueHealth `json:"queues"`
+}
+
+// Healthz - liveness, answers as long as the server is able to serve requests
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	Respond(w, 200, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// Readyz - readiness, fails while queues are drained on shutdown, when config failed to load
+// or when a project queue is full
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	res := Readiness{
+		Status:  "ready",
+		Reasons: make([]string, 0),
+		Queues:  make([]QueueHealth, 0),
+	}
+	if err := core.ConfigError(); err != nil {
+		res.Reasons = append(res.Reasons, fmt.Sprintf("config failed to load: %v", err))
+	}
+
+	for _, name := range sortedProjectNames() {
+		jq, ok := core.ProjectQueue(name)
+		if !ok {
+			continue
+		}
+		queue := QueueHealth{
+			Project:  name,
+			State:    QUEUE_ACCEPTING,
+			Depth:    jq.Len(),
+			Capacity: jq.Cap(),
+		}
+		switch {
+		case jq.Drained():
+			queue.State = QUEUE_DRAINING
+			res.Reasons = append(res.Reasons, fmt.Sprintf("queue %s is draining", name))
+		case jq.Full():
+			queue.State = QUEUE_FULL
+			res.Reasons = append(res.Reasons, fmt.Sprintf("queue %s is full", name))
+		}
+		if running, ok := core.History.Running(name); ok {
+			queue.Running = &RunningBuild{
+				ID:        running.ID,
+				Pipeline:  running.Pipeline,
+				StartedAt: running.LastBuildStart,
+			}
+		}
+		res.Queues = append(res.Queues, queue)
+	}
+
+	if len(res.Reasons) > 0 {
+		res.Status = "not ready"
+		Respond(w, http.StatusServiceUnavailable, res)
+		return
+	}
+	Respond(w, 200, res)
+}
@@ -0,0 +1,27 @@
+package httpinterface
+
+import (
+	"ghhooks.com/hook/core"
+	"ghhooks.com/hook/metrics"
+)
+
+var (
+	deliveriesTotal = metrics.NewCounterVec("ghhooks_webhook_deliveries_total",
+		"Webhook deliveries by event and verdict.", "event", "verdict")
+	signatureFailures = metrics.NewCounterVec("ghhooks_signature_failures_total",
+		"Webhook deliveries whose signature could not be verified.", "project")
+	websocketSubscribers = metrics.NewGaugeVec("ghhooks_websocket_subscribers",
+		"Open live status websocket connections.", "project")
+	sseSubscribers = metrics.NewGaugeVec("ghhooks_sse_subscribers",
+		"Open live status server-sent event streams.", "project")
+)
+
+// eventLabel - keeps event label values bounded, event header is set by whoever sends the request
+func eventLabel(event string) string {
+	switch event {
+	case "ping", core.EVENT_PUSH, core.EVENT_RELEASE, core.EVENT_PULL_REQUEST:
+		return event
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,93 @@
+package httpinterface
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"ghhooks.com/hook/core"
+)
+
+// BasePath - prefix of every route, empty when served at root
+func BasePath() string {
+	return core.Conf().BasePath
+}
+
+// peerTrusted - reports if the direct peer of a connection is a trusted proxy, peers on unix sockets are
+// local processes allowed by socket permissions and are trusted
+func peerTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return remoteAddr == "" || remoteAddr == "@"
+	}
+	return core.ContainsIP(core.Conf().TrustedNetworks(), ip)
+}
+
+// forwardedClient - client address of X-Forwarded-For, the right most address that is not a trusted proxy
+func forwardedClient(xff string) string {
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			return ""
+		}
+		if i == 0 || !core.ContainsIP(core.Conf().TrustedNetworks(), ip) {
+			return hop
+		}
+	}
+	return ""
+}
+
+func firstValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(value)
+}
+
+// TrustProxies - applies X-Forwarded-For, -Host and -Proto of trusted proxies to the request, so that
+// logging, url generation and ip allowlists see the client. forwarded headers of other peers are dropped
+func TrustProxies(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !peerTrusted(r.RemoteAddr) {
+			r.Header.Del("X-Forwarded-For")
+			r.Header.Del("X-Forwarded-Host")
+			r.Header.Del("X-Forwarded-Proto")
+			h.ServeHTTP(w, r)
+			return
+		}
+		if client := forwardedClient(r.Header.Get("X-Forwarded-For")); client != "" {
+			r.RemoteAddr = net.JoinHostPort(client, "0")
+		}
+		if host := firstValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			r.Host = host
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP - address of the client, nil when it is not known (unix socket without a proxy in front)
+func ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// IPAllowed - reports if webhook deliveries from the client are accepted for project
+func IPAllowed(r *http.Request, project core.Project) bool {
+	if len(project.AllowedIPs) == 0 {
+		return true
+	}
+	ip := ClientIP(r)
+	if ip == nil {
+		return false
+	}
+	// allowedIPs are validated when config is loaded
+	networks, _ := core.ParseNetworks(project.AllowedIPs)
+	return core.ContainsIP(networks, ip)
+}
@@ -0,0 +1,90 @@
+package httpinterface
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeerTrusted(t *testing.T) {
+	serverInit(t, `trustedProxies = ["10.0.0.0/8", "192.0.2.1"]`)
+	tests := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{"10.1.2.3:4000", true},
+		{"192.0.2.1:4000", true},
+		{"192.0.2.2:4000", false},
+		{"127.0.0.1:4000", false},
+		{"[::1]:4000", false},
+		{"10.1.2.3", true},
+		// unix socket peers
+		{"", true},
+		{"@", true},
+		{"not an address", false},
+	}
+	for _, tt := range tests {
+		if got := peerTrusted(tt.remoteAddr); got != tt.want {
+			t.Errorf("peerTrusted(%q) = %v, want %v", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestPeerTrustedDefault(t *testing.T) {
+	serverInit(t, "")
+	for remoteAddr, want := range map[string]bool{"127.0.0.1:4000": true, "[::1]:4000": true, "10.1.2.3:4000": false} {
+		if got := peerTrusted(remoteAddr); got != want {
+			t.Errorf("peerTrusted(%q) = %v, want %v", remoteAddr, got, want)
+		}
+	}
+}
+
+func TestForwardedClient(t *testing.T) {
+	serverInit(t, `trustedProxies = ["10.0.0.0/8"]`)
+	tests := []struct {
+		xff  string
+		want string
+	}{
+		{"203.0.113.7", "203.0.113.7"},
+		{"203.0.113.7, 10.0.0.2", "203.0.113.7"},
+		{"203.0.113.7,10.0.0.3, 10.0.0.2", "203.0.113.7"},
+		// only the right most untrusted hop can be believed, the client can put anything before it
+		{"198.51.100.1, 203.0.113.7, 10.0.0.2", "203.0.113.7"},
+		{"10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"203.0.113.7, garbage", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := forwardedClient(tt.xff); got != tt.want {
+			t.Errorf("forwardedClient(%q) = %q, want %q", tt.xff, got, tt.want)
+		}
+	}
+}
+
+func TestTrustProxies(t *testing.T) {
+	serverInit(t, `trustedProxies = ["10.0.0.0/8"]`)
+	var got *http.Request
+	h := TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	r.Header.Set("X-Forwarded-Host", "hooks.example.com, internal")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if ClientIP(got).String() != "203.0.113.7" || got.Host != "hooks.example.com" || RequestScheme(got) != "https" {
+		t.Errorf("through trusted proxy: client %s host %s scheme %s", ClientIP(got), got.Host, RequestScheme(got))
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.9:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if ClientIP(got).String() != "203.0.113.9" || RequestScheme(got) != "http" || got.Header.Get("X-Forwarded-For") != "" {
+		t.Errorf("from untrusted peer: client %s scheme %s, forwarded headers should be dropped", ClientIP(got), RequestScheme(got))
+	}
+}
@@ -0,0 +1,85 @@
+package httpinterface
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+// status page, dashboard and their stylesheet are built into the binary, a templates directory can
+// override any of them by file name (templates at its root, stylesheet and other assets under static/).
+// templates get a basePath function returning the prefix routes are served under
+
+//go:embed web
+var webFiles embed.FS
+
+var (
+	templates *template.Template
+	staticFS  fs.FS
+)
+
+// overlayFS - opens files from dir first and falls back to base for files dir does not have
+type overlayFS struct {
+	dir  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.dir.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.base.Open(name)
+	}
+	return f, err
+}
+
+// LoadTemplates - parses page templates once, from the embedded ones or from dir when it is set
+func LoadTemplates(dir string) error {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+		files = overlayFS{dir: os.DirFS(dir), base: files}
+	}
+
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"basePath": BasePath,
+	}).ParseFS(files, "statuspage.html", "dashboard.html")
+	if err != nil {
+		return err
+	}
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return err
+	}
+	templates, staticFS = tmpl, static
+	return nil
+}
+
+// renderTemplate - executes page template into a buffer first, so that a broken template is answered
+// with 500 instead of a partial or blank page
+func renderTemplate(w http.ResponseWriter, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("template %s: %v", name, err)
+		http.Error(w, "could not render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// Static - serves stylesheet and other assets of the pages
+func Static() http.Handler {
+	return http.StripPrefix(BasePath()+"/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.FS(staticFS)).ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,33 @@
+package httpinterface
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	dir := t.TempDir()
+	broken := `<h1>{{.NoSuchField}}</h1>`
+	if err := os.WriteFile(filepath.Join(dir, "dashboard.html"), []byte(broken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTemplates(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { LoadTemplates("") })
+
+	w := httptest.NewRecorder()
+	renderTemplate(w, "dashboard.html", DashboardResponse{})
+	if w.Code != 500 || strings.Contains(w.Body.String(), "<h1>") {
+		t.Errorf("broken template = %d %q, want 500 without partial page", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	renderTemplate(w, "statuspage.html", StatusResponse{ProjectName: "p"})
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("status page = %d %s, want 200 text/html", w.Code, w.Header().Get("Content-Type"))
+	}
+}
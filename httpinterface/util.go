@@ -10,8 +10,20 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"ghhooks.com/hook/core"
 )
 
+// SkipError is returned by VerifyEvent when event is valid but build should not run
+type SkipError struct {
+	Commit string
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return e.Reason
+}
+
 func Respond(w http.ResponseWriter, statusCode int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
@@ -33,53 +45,196 @@ func VerifySignature(payload []byte, hash, key string) (bool, error) {
 
 }
 
-// VirifyType - check if a given event  type is supported
-func VerifyEvent(eventType string, bodyInBytes []byte, configBranchName string) error {
+// VirifyType - check if a given event  type is supported, returns what triggered the build and
+// the names of project pipelines that should run for it
+func VerifyEvent(eventType string, bodyInBytes []byte, project core.Project) (core.Trigger, []string, error) {
+	trigger := core.Trigger{Event: eventType}
 	switch eventType {
 
-	case "push":
+	case core.EVENT_PUSH:
 
 		var payload WebhookPayload
 		err := json.Unmarshal(bodyInBytes, &payload)
 		if err != nil {
-			return fmt.Errorf("Couldnt unmarshal push event  - %v", err)
+			return trigger, nil, fmt.Errorf("Couldnt unmarshal push event  - %v", err)
 		}
 
 		if payload.Ref == "" {
-			return fmt.Errorf("invalid payload: cannot find ref inside given payload")
+			return trigger, nil, fmt.Errorf("invalid payload: cannot find ref inside given payload")
 		}
 
-		branchStringArr := strings.Split(payload.Ref, "/")
-		branchString := branchStringArr[len(branchStringArr)-1]
-
-		if configBranchName != branchString {
-			return fmt.Errorf("request recieved but the push event is not for the configured branch")
+		trigger.Ref = payload.Ref
+		trigger.Commit = payload.After
+
+		if payload.Deleted {
+			trigger.Deleted = true
+			trigger.Commit = payload.Before
+			pipelines := project.Route(trigger)
+			if len(pipelines) == 0 {
+				return trigger, nil, &SkipError{
+					Commit: payload.Before,
+					Reason: "branch was deleted",
+				}
+			}
+			return trigger, pipelines, nil
 		}
 
-		return nil
+		pipelines := project.Route(trigger)
+		if len(pipelines) == 0 {
+			return trigger, nil, fmt.Errorf("request recieved but the push event is not for the configured branch")
+		}
 
-	case "release":
-		supportedReleaseActions := map[string]bool{
-			"published": true,
-			"created":   false,
-			"released":  false,
+		if reason := SkipReason(payload, project); reason != "" {
+			return trigger, nil, &SkipError{
+				Commit: payload.HeadCommit.ID,
+				Reason: reason,
+			}
 		}
 
+		return trigger, pipelines, nil
+
+	case core.EVENT_RELEASE:
 		var payload ReleaseWebhookPayload
 		err := json.Unmarshal(bodyInBytes, &payload)
 
 		if err != nil {
-			return fmt.Errorf("couldnt unmarshal release event  - %v", err)
+			return trigger, nil, fmt.Errorf("couldnt unmarshal release event  - %v", err)
+		}
+		if !project.AcceptsReleaseAction(payload.Action) {
+			return trigger, nil, fmt.Errorf("release event  action %s is not enabled", payload.Action)
+		}
+
+		release := payload.Release
+		if release.Prerelease && project.SkipPrereleases {
+			return trigger, nil, fmt.Errorf("request recieved but release %s is a prerelease", release.TagName)
 		}
-		supported, exists := supportedReleaseActions[payload.Action]
-		if exists && supported {
-			return nil
+
+		trigger.Tag = release.TagName
+		if release.TagName != "" {
+			trigger.Ref = "refs/tags/" + release.TagName
+		}
+
+		pipelines := project.Route(trigger)
+		if len(pipelines) == 0 {
+			return trigger, nil, fmt.Errorf("request recieved but release tag %s does not match configured tags", release.TagName)
 		}
-		return fmt.Errorf("release event  action %s is not enabled", payload.Action)
+		return trigger, pipelines, nil
+
+	case core.EVENT_PULL_REQUEST:
+		supportedPullRequestActions := map[string]bool{
+			"opened":      true,
+			"synchronize": true,
+			"reopened":    true,
+		}
+
+		var payload PullRequestWebhookPayload
+		err := json.Unmarshal(bodyInBytes, &payload)
+		if err != nil {
+			return trigger, nil, fmt.Errorf("couldnt unmarshal pull request event  - %v", err)
+		}
+		if !supportedPullRequestActions[payload.Action] {
+			return trigger, nil, fmt.Errorf("pull request event action %s is not enabled", payload.Action)
+		}
+
+		trigger.Ref = payload.PullRequest.Head.Ref
+		trigger.Commit = payload.PullRequest.Head.SHA
+		trigger.PR = payload.Number
+		trigger.Base = payload.PullRequest.Base.Ref
+
+		pipelines := project.Route(trigger)
+		if len(pipelines) == 0 {
+			return trigger, nil, fmt.Errorf("request recieved but no pipeline builds pull requests against %s", trigger.Base)
+		}
+		return trigger, pipelines, nil
 
 	default:
-		return fmt.Errorf("event type %s: is not supported", eventType)
+		return trigger, nil, fmt.Errorf("event type %s: is not supported", eventType)
+	}
+}
+
+// VerifyPing - checks hook config sent with ping event, returns warnings for config that
+// wont stop builds but probably isnt intended
+func VerifyPing(bodyInBytes []byte) ([]string, error) {
+	supportedEvents := map[string]bool{
+		"*":            true,
+		"ping":         true,
+		"push":         true,
+		"release":      true,
+		"pull_request": true,
+	}
+
+	var payload PingWebhookPayload
+	err := json.Unmarshal(bodyInBytes, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt unmarshal ping event  - %v", err)
+	}
+
+	if payload.Hook.Config.ContentType != "" && payload.Hook.Config.ContentType != "json" {
+		return nil, fmt.Errorf("hook content type %s is not supported, use application/json", payload.Hook.Config.ContentType)
+	}
+
+	warnings := make([]string, 0)
+	for _, event := range payload.Hook.Events {
+		if !supportedEvents[event] {
+			warnings = append(warnings, fmt.Sprintf("event type %s is not supported and will be rejected", event))
+		}
+	}
+	if !payload.Hook.Active {
+		warnings = append(warnings, "hook is not active")
+	}
+	return warnings, nil
+}
+
+// SkipReason - returns why push should not be built, empty string if it should
+func SkipReason(payload WebhookPayload, project core.Project) string {
+	if project.ForceBuild {
+		return ""
+	}
+	markers := project.Markers()
+	if marker := findMarker(payload.HeadCommit.Message, markers); marker != "" {
+		return fmt.Sprintf("head commit message contains %s", marker)
+	}
+	if len(payload.Commits) == 0 {
+		return ""
+	}
+	for _, commit := range payload.Commits {
+		if findMarker(commit.Message, markers) == "" {
+			return ""
+		}
+	}
+	return fmt.Sprintf("all %d commits contain a skip marker", len(payload.Commits))
+}
+
+func findMarker(message string, markers []string) string {
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(message, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// PipelineFromQuery - returns pipeline asked for with ?pipeline=, project default pipeline when it is not set
+func PipelineFromQuery(r *http.Request, project core.Project) (string, core.Pipeline, bool) {
+	name := r.URL.Query().Get("pipeline")
+	if name == "" {
+		name = project.DefaultPipeline()
+	}
+	pipeline, ok := project.Pipelines()[name]
+	return name, pipeline, ok
+}
+
+// RequestScheme - scheme the client used to reach the server, X-Forwarded-Proto is used when it is set
+// (TrustProxies drops it unless it comes from a trusted proxy)
+func RequestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		proto, _, _ = strings.Cut(proto, ",")
+		return strings.ToLower(strings.TrimSpace(proto))
+	}
+	if r.TLS != nil {
+		return "https"
 	}
+	return "http"
 }
 
 func StreamToByte(stream io.Reader) ([]byte, error) {
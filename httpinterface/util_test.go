@@ -0,0 +1,178 @@
+package httpinterface
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ghhooks.com/hook/core"
+)
+
+func pushPayload(t *testing.T, p WebhookPayload) []byte {
+	t.Helper()
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestVerifyEvent(t *testing.T) {
+	project := core.Project{
+		Branch:        "main",
+		Steps:         [][]string{{"true"}},
+		PRSteps:       [][]string{{"true"}},
+		PRBranches:    []string{"main"},
+		TeardownSteps: [][]string{{"true"}},
+		ReleaseTags:   []string{"v*"},
+	}
+	tests := []struct {
+		name      string
+		event     string
+		body      []byte
+		project   core.Project
+		trigger   core.Trigger
+		pipelines []string
+		skip      string
+		err       string
+	}{
+		{
+			name:      "push to branch",
+			event:     core.EVENT_PUSH,
+			body:      pushPayload(t, WebhookPayload{Ref: "refs/heads/main", After: "b", HeadCommit: CommitT{ID: "b", Message: "fix"}}),
+			trigger:   core.Trigger{Event: core.EVENT_PUSH, Ref: "refs/heads/main", Commit: "b"},
+			pipelines: []string{core.DEFAULT_PIPELINE},
+		},
+		{
+			name:  "push to other branch",
+			event: core.EVENT_PUSH,
+			body:  pushPayload(t, WebhookPayload{Ref: "refs/heads/dev", After: "b"}),
+			err:   "not for the configured branch",
+		},
+		{
+			name:  "push without ref",
+			event: core.EVENT_PUSH,
+			body:  pushPayload(t, WebhookPayload{After: "b"}),
+			err:   "cannot find ref",
+		},
+		{
+			name:  "push with skip marker",
+			event: core.EVENT_PUSH,
+			body:  pushPayload(t, WebhookPayload{Ref: "refs/heads/main", After: "b", HeadCommit: CommitT{ID: "b", Message: "docs [skip ci]"}}),
+			skip:  "head commit message contains [skip ci]",
+		},
+		{
+			name:      "branch deleted",
+			event:     core.EVENT_PUSH,
+			body:      pushPayload(t, WebhookPayload{Ref: "refs/heads/main", Before: "a", After: "0000", Deleted: true}),
+			trigger:   core.Trigger{Event: core.EVENT_PUSH, Ref: "refs/heads/main", Commit: "a", Deleted: true},
+			pipelines: []string{"teardown"},
+		},
+		{
+			name:    "branch deleted without teardown",
+			event:   core.EVENT_PUSH,
+			body:    pushPayload(t, WebhookPayload{Ref: "refs/heads/main", Before: "a", Deleted: true}),
+			project: core.Project{Branch: "main", Steps: [][]string{{"true"}}},
+			skip:    "branch was deleted",
+		},
+		{
+			name:  "release tag not matching",
+			event: core.EVENT_RELEASE,
+			body:  []byte(`{"action": "published", "release": {"tag_name": "nightly"}}`),
+			err:   "does not match configured tags",
+		},
+		{
+			name:  "release action not enabled",
+			event: core.EVENT_RELEASE,
+			body:  []byte(`{"action": "created", "release": {"tag_name": "v1.0.0"}}`),
+			err:   "action created is not enabled",
+		},
+		{
+			name:  "pull request closed",
+			event: core.EVENT_PULL_REQUEST,
+			body:  []byte(`{"action": "closed", "number": 1}`),
+			err:   "action closed is not enabled",
+		},
+		{
+			name:  "pull request against other base",
+			event: core.EVENT_PULL_REQUEST,
+			body:  []byte(`{"action": "opened", "number": 1, "pull_request": {"head": {"ref": "x"}, "base": {"ref": "dev"}}}`),
+			err:   "no pipeline builds pull requests against dev",
+		},
+		{
+			name:  "invalid json",
+			event: core.EVENT_PUSH,
+			body:  []byte(`{`),
+			err:   "unmarshal push event",
+		},
+		{
+			name:  "unsupported event",
+			event: "issues",
+			body:  []byte(`{}`),
+			err:   "event type issues: is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := project
+			if tt.project.Branch != "" {
+				p = tt.project
+			}
+			trigger, pipelines, err := VerifyEvent(tt.event, tt.body, p)
+
+			var skipErr *SkipError
+			switch {
+			case tt.skip != "":
+				if !errors.As(err, &skipErr) || skipErr.Reason != tt.skip {
+					t.Fatalf("VerifyEvent() error = %v, want skip %q", err, tt.skip)
+				}
+				return
+			case tt.err != "":
+				if err == nil || errors.As(err, &skipErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("VerifyEvent() error = %v, want error containing %q", err, tt.err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("VerifyEvent() error = %v", err)
+			}
+			if trigger != tt.trigger || !reflect.DeepEqual(pipelines, tt.pipelines) {
+				t.Errorf("VerifyEvent() = %+v %v, want %+v %v", trigger, pipelines, tt.trigger, tt.pipelines)
+			}
+		})
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	commits := func(messages ...string) []CommitT {
+		c := make([]CommitT, len(messages))
+		for i, m := range messages {
+			c[i] = CommitT{Message: m}
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		project core.Project
+		head    string
+		commits []CommitT
+		want    string
+	}{
+		{name: "no marker", head: "fix build", commits: commits("fix build"), want: ""},
+		{name: "head commit marker", head: "docs [ci skip]", want: "head commit message contains [ci skip]"},
+		{name: "every commit marked", head: "merge", commits: commits("a [skip ci]", "b [skip deploy]"), want: "all 2 commits contain a skip marker"},
+		{name: "one commit unmarked", head: "merge", commits: commits("a [skip ci]", "b"), want: ""},
+		{name: "configured markers", project: core.Project{SkipMarkers: []string{"#nobuild"}}, head: "wip #nobuild", want: "head commit message contains #nobuild"},
+		{name: "default markers replaced", project: core.Project{SkipMarkers: []string{"#nobuild"}}, head: "docs [skip ci]", want: ""},
+		{name: "forced", project: core.Project{ForceBuild: true}, head: "docs [skip ci]", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := WebhookPayload{HeadCommit: CommitT{Message: tt.head}, Commits: tt.commits}
+			if got := SkipReason(payload, tt.project); got != tt.want {
+				t.Errorf("SkipReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -130,6 +130,14 @@ type RepositoryT struct {
 	WebCommitSignoffRequired bool      `json:"web_commit_signoff_required"`
 }
 
+// EventRepositoryT - repository sent with events other than push, which send created_at and
+// pushed_at as dates instead of unix timestamps
+type EventRepositoryT struct {
+	RepositoryT
+	CreatedAt time.Time `json:"created_at"`
+	PushedAt  time.Time `json:"pushed_at"`
+}
+
 type PusherT struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
@@ -170,6 +178,98 @@ type WebhookPayload struct {
 	Sender     SenderT     `json:"sender"`
 }
 
+type ReleaseAssetT struct {
+	BrowserDownloadURL string    `json:"browser_download_url"`
+	ContentType        string    `json:"content_type"`
+	CreatedAt          time.Time `json:"created_at"`
+	DownloadCount      int       `json:"download_count"`
+	ID                 int       `json:"id"`
+	Label              string    `json:"label"`
+	Name               string    `json:"name"`
+	NodeID             string    `json:"node_id"`
+	Size               int       `json:"size"`
+	State              string    `json:"state"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	Uploader           SenderT   `json:"uploader"`
+	URL                string    `json:"url"`
+}
+
+type ReleaseT struct {
+	Assets          []ReleaseAssetT `json:"assets"`
+	AssetsURL       string          `json:"assets_url"`
+	Author          SenderT         `json:"author"`
+	Body            string          `json:"body"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Draft           bool            `json:"draft"`
+	HTMLURL         string          `json:"html_url"`
+	ID              int             `json:"id"`
+	Name            string          `json:"name"`
+	NodeID          string          `json:"node_id"`
+	Prerelease      bool            `json:"prerelease"`
+	PublishedAt     time.Time       `json:"published_at"`
+	TagName         string          `json:"tag_name"`
+	TarballURL      string          `json:"tarball_url"`
+	TargetCommitish string          `json:"target_commitish"`
+	UploadURL       string          `json:"upload_url"`
+	URL             string          `json:"url"`
+	ZipballURL      string          `json:"zipball_url"`
+}
+
 type ReleaseWebhookPayload struct {
-	Action string `json:"action"`
+	Action     string           `json:"action"`
+	Release    ReleaseT         `json:"release"`
+	Repository EventRepositoryT `json:"repository"`
+	Sender     SenderT          `json:"sender"`
+}
+
+type PullRequestBranchT struct {
+	Label string           `json:"label"`
+	Ref   string           `json:"ref"`
+	SHA   string           `json:"sha"`
+	User  SenderT          `json:"user"`
+	Repo  EventRepositoryT `json:"repo"`
+}
+
+type PullRequestT struct {
+	ID      int                `json:"id"`
+	Number  int                `json:"number"`
+	State   string             `json:"state"`
+	Title   string             `json:"title"`
+	HTMLURL string             `json:"html_url"`
+	Draft   bool               `json:"draft"`
+	Merged  bool               `json:"merged"`
+	User    SenderT            `json:"user"`
+	Head    PullRequestBranchT `json:"head"`
+	Base    PullRequestBranchT `json:"base"`
+}
+
+type PullRequestWebhookPayload struct {
+	Action      string           `json:"action"`
+	Number      int              `json:"number"`
+	PullRequest PullRequestT     `json:"pull_request"`
+	Repository  EventRepositoryT `json:"repository"`
+	Sender      SenderT          `json:"sender"`
+}
+
+type HookConfigT struct {
+	ContentType string `json:"content_type"`
+	InsecureSSL string `json:"insecure_ssl"`
+	URL         string `json:"url"`
+}
+
+type HookT struct {
+	Active bool        `json:"active"`
+	Config HookConfigT `json:"config"`
+	Events []string    `json:"events"`
+	ID     int         `json:"id"`
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+}
+
+type PingWebhookPayload struct {
+	Hook       HookT            `json:"hook"`
+	HookID     int              `json:"hook_id"`
+	Repository EventRepositoryT `json:"repository"`
+	Sender     SenderT          `json:"sender"`
+	Zen        string           `json:"zen"`
 }
@@ -0,0 +1,101 @@
+package httpinterface
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ghhooks.com/hook/core"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReleasePayload(t *testing.T) {
+	body := readFixture(t, "release.json")
+
+	var payload ReleaseWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decoding release payload: %v", err)
+	}
+	if payload.Release.TagName != "0.0.1" {
+		t.Errorf("tag = %q, want 0.0.1", payload.Release.TagName)
+	}
+	if payload.Repository.FullName != "Codertocat/Hello-World" {
+		t.Errorf("repository = %q, want Codertocat/Hello-World", payload.Repository.FullName)
+	}
+	pushedAt := time.Date(2019, 5, 15, 15, 20, 52, 0, time.UTC)
+	if !payload.Repository.PushedAt.Equal(pushedAt) {
+		t.Errorf("pushed_at = %v, want %v", payload.Repository.PushedAt, pushedAt)
+	}
+
+	project := core.Project{Branch: "master", Steps: [][]string{{"true"}}}
+	trigger, pipelines, err := VerifyEvent(core.EVENT_RELEASE, body, project)
+	if err != nil {
+		t.Fatalf("VerifyEvent: %v", err)
+	}
+	if trigger.Ref != "refs/tags/0.0.1" || len(pipelines) != 1 || pipelines[0] != core.DEFAULT_PIPELINE {
+		t.Errorf("VerifyEvent = %+v %v, want refs/tags/0.0.1 [%s]", trigger, pipelines, core.DEFAULT_PIPELINE)
+	}
+}
+
+func TestPullRequestPayload(t *testing.T) {
+	body := readFixture(t, "pull_request.json")
+
+	var payload PullRequestWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decoding pull request payload: %v", err)
+	}
+	if payload.PullRequest.Head.Repo.FullName != "Octocoders/Hello-World" {
+		t.Errorf("head repository = %q, want Octocoders/Hello-World", payload.PullRequest.Head.Repo.FullName)
+	}
+	createdAt := time.Date(2019, 5, 15, 15, 19, 25, 0, time.UTC)
+	if !payload.Repository.CreatedAt.Equal(createdAt) || !payload.PullRequest.Base.Repo.CreatedAt.Equal(createdAt) {
+		t.Errorf("created_at = %v, want %v", payload.Repository.CreatedAt, createdAt)
+	}
+
+	project := core.Project{Branch: "master", Steps: [][]string{{"true"}}, PRSteps: [][]string{{"true"}}}
+	trigger, pipelines, err := VerifyEvent(core.EVENT_PULL_REQUEST, body, project)
+	if err != nil {
+		t.Fatalf("VerifyEvent: %v", err)
+	}
+	want := core.Trigger{
+		Event:  core.EVENT_PULL_REQUEST,
+		Ref:    "changes",
+		Commit: "ec26c3e57ca3a959ca5aad62de7213c562f8c821",
+		PR:     2,
+		Base:   "master",
+	}
+	if trigger != want || len(pipelines) != 1 || pipelines[0] != "pr" {
+		t.Errorf("VerifyEvent = %+v %v, want %+v [pr]", trigger, pipelines, want)
+	}
+}
+
+func TestPingPayload(t *testing.T) {
+	body := readFixture(t, "ping.json")
+
+	var payload PingWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decoding ping payload: %v", err)
+	}
+	if payload.HookID != 30 || payload.Repository.FullName != "Codertocat/Hello-World" {
+		t.Errorf("hook %d of %q, want 30 of Codertocat/Hello-World", payload.HookID, payload.Repository.FullName)
+	}
+
+	warnings, err := VerifyPing(body)
+	if err != nil {
+		t.Fatalf("VerifyPing: %v", err)
+	}
+	want := "event type issues is not supported and will be rejected"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("VerifyPing warnings = %q, want [%q]", warnings, want)
+	}
+}
@@ -28,6 +28,11 @@ type JobQueue struct {
 	concurrentWorkers uint64
 	l                 *log.Logger
 	wg                *sync.WaitGroup
+	mu                sync.RWMutex
+	drained           bool
+	// workers of this queue only, done is closed once all of them exited
+	workers sync.WaitGroup
+	done    chan struct{}
 }
 
 type QueueMap map[string]*JobQueue
@@ -41,10 +46,17 @@ func NewJobQueue(pname string, pbuffer chan Job, pconcurrentWorkers uint64, l *l
 		concurrentWorkers: pconcurrentWorkers,
 		l:                 l,
 		wg:                wg,
+		done:              make(chan struct{}),
 	}
 }
 
 func (jq *JobQueue) Enqueue(job Job) bool {
+	// buffer is closed once queue is drained, sending on it would panic
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+	if jq.drained {
+		return false
+	}
 	select {
 	case jq.buffer <- job:
 		return true
@@ -53,6 +65,20 @@ func (jq *JobQueue) Enqueue(job Job) bool {
 	}
 }
 
+func (jq *JobQueue) Name() string {
+	return jq.name
+}
+
+// Len - number of jobs waiting in queue
+func (jq *JobQueue) Len() int {
+	return len(jq.buffer)
+}
+
+// Cap - number of jobs queue can hold
+func (jq *JobQueue) Cap() int {
+	return cap(jq.buffer)
+}
+
 func (jq *JobQueue) startWorker() {
 	for j := range jq.buffer {
 		err := j.Action(j.Args...)
@@ -61,24 +87,78 @@ func (jq *JobQueue) startWorker() {
 		}
 		jq.l.Println("job done")
 	}
+	jq.workers.Done()
 	jq.wg.Done()
 }
 
 func (jq *JobQueue) StartWorkers() {
+	jq.StartWorkersAfter(nil)
+}
+
+// StartWorkersAfter - starts workers once previous is closed, jobs can be queued meanwhile.
+// workers are counted in the wait group right away so that shutdown waits for them
+func (jq *JobQueue) StartWorkersAfter(previous <-chan struct{}) {
 	if jq.concurrentWorkers == 0 {
 		jq.concurrentWorkers = 1
 	}
-	for i := 0; i < int(jq.concurrentWorkers); i++ {
-		jq.wg.Add(1)
-		go jq.startWorker()
+	n := int(jq.concurrentWorkers)
+	jq.wg.Add(n)
+	jq.workers.Add(n)
+	go func() {
+		jq.workers.Wait()
+		close(jq.done)
+	}()
+
+	start := func() {
+		for i := 0; i < n; i++ {
+			go jq.startWorker()
+		}
+	}
+	if previous == nil {
+		start()
+		return
 	}
+	go func() {
+		<-previous
+		start()
+	}()
+}
+
+// Done - closed once queue is drained and its workers finished their last job
+func (jq *JobQueue) Done() <-chan struct{} {
+	return jq.done
+}
+
+// Drained - reports if queue has been drained and no longer accepts jobs
+func (jq *JobQueue) Drained() bool {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+	return jq.drained
+}
+
+// Full - reports if queue can not take any more jobs
+func (jq *JobQueue) Full() bool {
+	return jq.Len() >= jq.Cap()
 }
 
-func (jg *JobQueue) Drain() {
+// Drain - closes queue and returns the jobs that were waiting in it, they are not run
+func (jg *JobQueue) Drain() []Job {
+	jg.mu.Lock()
+	if jg.drained {
+		jg.mu.Unlock()
+		return nil
+	}
+	jg.drained = true
 	close(jg.buffer)
+	jg.mu.Unlock()
+	dropped := make([]Job, 0)
 	for len(jg.buffer) > 0 {
-		<-jg.buffer
+		// workers take jobs too, a job is only dropped when it was received here
+		if j, ok := <-jg.buffer; ok {
+			dropped = append(dropped, j)
+		}
 	}
+	return dropped
 }
 
 func (q *QueueMap) Register(jq *JobQueue) error {
@@ -95,10 +175,12 @@ func (q *QueueMap) StartAll() {
 	}
 }
 
-func (q *QueueMap) DrainAll() {
+func (q *QueueMap) DrainAll() []Job {
+	dropped := make([]Job, 0)
 	for k := range *q {
-		(*q)[k].Drain()
+		dropped = append(dropped, (*q)[k].Drain()...)
 	}
+	return dropped
 }
 
 func (q *QueueMap) Enqueue(queueName string, job Job) bool {
@@ -0,0 +1,66 @@
+package jobqueue
+
+import (
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrainReturnsQueuedJobs(t *testing.T) {
+	var wg sync.WaitGroup
+	jq := NewJobQueue("p", make(chan Job, 3), 1, log.New(io.Discard, "", 0), &wg)
+	for _, name := range []string{"a", "b"} {
+		if !jq.Enqueue(Job{Name: name}) {
+			t.Fatalf("could not enqueue %s", name)
+		}
+	}
+	dropped := jq.Drain()
+	if len(dropped) != 2 || dropped[0].Name != "a" || dropped[1].Name != "b" {
+		t.Errorf("Drain() = %v, want jobs a and b", dropped)
+	}
+	if jq.Enqueue(Job{Name: "c"}) {
+		t.Error("drained queue accepted a job")
+	}
+	if dropped := jq.Drain(); len(dropped) != 0 {
+		t.Errorf("second Drain() = %v, want none", dropped)
+	}
+}
+
+func TestStartWorkersAfter(t *testing.T) {
+	var wg sync.WaitGroup
+	l := log.New(io.Discard, "", 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	old := NewJobQueue("p", make(chan Job, 1), 1, l, &wg)
+	old.StartWorkers()
+	old.Enqueue(Job{Action: func(...any) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	<-started
+
+	ran := make(chan struct{})
+	jq := NewJobQueue("p", make(chan Job, 1), 1, l, &wg)
+	jq.StartWorkersAfter(old.Done())
+	jq.Enqueue(Job{Action: func(...any) error {
+		close(ran)
+		return nil
+	}})
+
+	old.Drain()
+	select {
+	case <-ran:
+		t.Fatal("job ran before worker of previous queue exited")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run once worker of previous queue exited")
+	}
+	jq.Drain()
+	wg.Wait()
+}
@@ -0,0 +1,79 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// addresses with this prefix are unix socket paths, e.g. unix:/run/ghhooks.sock
+const UNIX_PREFIX = "unix:"
+
+// first file descriptor passed by systemd socket activation (after stdin, stdout and stderr)
+const LISTEN_FDS_START = 3
+
+// Listen - unix socket listener for unix: addresses (created with given permissions), tcp listener otherwise
+func Listen(addr string, mode fs.FileMode) (net.Listener, error) {
+	if !strings.HasPrefix(addr, UNIX_PREFIX) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, UNIX_PREFIX)
+	// socket left behind by a process that did not shut down cleanly
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Activated - listeners passed by systemd socket activation in order of the socket unit's ListenStream=
+// lines, nil when the process was not socket activated. environment variables are unset so that build
+// steps do not inherit them
+func Activated() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("LISTEN_FDS is set but holds no file descriptors")
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := LISTEN_FDS_START; fd < LISTEN_FDS_START+n; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(file)
+		// FileListener dups the descriptor
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket activation fd %d: %v", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
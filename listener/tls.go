@@ -0,0 +1,128 @@
+package listener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"ghhooks.com/hook/core"
+)
+
+// how often certificate and key files are checked for changes
+const CERT_POLL_INTERVAL = 10 * time.Second
+
+// CertReloader serves the certificate it last loaded, certificates are swapped in place so that
+// open connections are not affected by a reload
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := cr.Load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Load - reads certificate and key from disk, the previous certificate stays in use when they are invalid
+func (cr *CertReloader) Load() error {
+	modTime, err := cr.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// lastModified - latest modification time of certificate and key files
+func (cr *CertReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Watch - reloads certificate whenever its files change until stop is closed, a failed reload is retried
+// on the next check since certificate and key are usually not replaced at once
+func (cr *CertReloader) Watch(l *log.Logger, stop <-chan struct{}) {
+	ticker := time.NewTicker(CERT_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			modTime, err := cr.lastModified()
+			cr.mu.RLock()
+			changed := err == nil && !modTime.Equal(cr.modTime)
+			cr.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := cr.Load(); err != nil {
+				l.Printf("tls certificate reload failed: %v\n", err)
+				continue
+			}
+			l.Printf("tls certificate reloaded from %s\n", cr.certFile)
+		}
+	}
+}
+
+// TLSConfig - server tls config serving certificate of reloader, client certificates are required and
+// verified when a client CA is configured
+func TLSConfig(conf core.TLSConf, cr *CertReloader) (*tls.Config, error) {
+	version, err := conf.Version()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     version,
+		GetCertificate: cr.GetCertificate,
+	}
+	if conf.ClientCA != "" {
+		pem, err := os.ReadFile(conf.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", conf.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
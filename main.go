@@ -4,14 +4,21 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 
 	"ghhooks.com/hook/core"
 	"ghhooks.com/hook/httpinterface"
+	"ghhooks.com/hook/listener"
+	"ghhooks.com/hook/metrics"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
@@ -36,30 +43,163 @@ const INIT = `
 `
 
 func main() {
-	configFileLocation := flag.String("config", "example.toml", "location of config file")
+	flag.Usage = usage
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %s\n\n", os.Args[1])
+			commandUsage()
+			os.Exit(2)
+		}
+		os.Exit(command(os.Args[2:]))
+	}
+	configFileLocation, configFormat := configFlags(flag.CommandLine)
 	httpLogger := flag.Bool("httplog", true, "log http requests (webhook push event and status request)")
-	addr := flag.String("addr", ":4444", "address/port pair")
+	addr := flag.String("addr", ":4444", "address/port pair or unix:/path/to.sock, ignored when sockets are passed by systemd")
+	metricsAddr := flag.String("metrics-addr", "", "address/port pair (or unix:/path/to.sock) to serve /metrics on, served along with everything else when empty")
+	socketMode := flag.String("socket-mode", "0660", "permissions of unix sockets created for unix: addresses")
+	tlsCert := flag.String("tls-cert", "", "certificate file to serve https with, tls.cert config key when empty")
+	tlsKey := flag.String("tls-key", "", "key file of -tls-cert, tls.key config key when empty")
+	tlsMinVersion := flag.String("tls-min-version", "", "minimum tls version (1.0, 1.1, 1.2, 1.3), 1.2 when empty")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA bundle client certificates are verified against, enables mutual tls")
+	templatesDir := flag.String("templates", "", "directory with templates overriding the built in pages, templates config key when empty")
+	strictConfig := flag.Bool("strict-config", false, "refuse to start or reload config when hook validate reports problems")
 	flag.Parse()
+	core.StrictConfig = *strictConfig
+	if err := useConfigFormat(*configFormat); err != nil {
+		log.Fatal(err)
+	}
 
 	l := log.New(os.Stdout, "", 0)
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		log.Fatalf("invalid socket mode %s, expected octal permissions", *socketMode)
+	}
+	// with systemd socket activation the first socket serves everything and the second one metrics,
+	// systemd keeps accepting connections while ghhooks restarts
+	activated, err := listener.Activated()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var wg sync.WaitGroup
-	err := core.ServerInit(*configFileLocation, l, &wg)
+	err = core.ServerInit(*configFileLocation, l, &wg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *templatesDir == "" {
+		*templatesDir = core.Conf().Templates
+	}
+	if err := httpinterface.LoadTemplates(*templatesDir); err != nil {
+		log.Fatal(err)
+	}
 	r := mux.NewRouter()
-	httpinterface.RouterInit(r)
+	routes := r
+	if base := core.Conf().BasePath; base != "" {
+		routes = r.PathPrefix(base).Subrouter()
+		r.Handle(base, http.RedirectHandler(base+"/", http.StatusMovedPermanently))
+	}
+	var metricsSrv *http.Server
+	var metricsLn net.Listener
+	switch {
+	case len(activated) > 1:
+		metricsLn = activated[1]
+	case *metricsAddr != "":
+		metricsLn, err = listener.Listen(*metricsAddr, fs.FileMode(mode))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if metricsLn == nil {
+		routes.Handle("/metrics", metrics.Handler()).Methods("GET")
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Handler: metricsMux,
+		}
+	}
+	httpinterface.RouterInit(routes)
 	var handler http.Handler = r
 	if *httpLogger {
 		handler = handlers.LoggingHandler(os.Stdout, handler)
 	}
+	// outermost so that logs have the client address forwarded by trusted proxies
+	handler = httpinterface.TrustProxies(handler)
 
 	srv := &http.Server{
 		Handler: handler,
-		Addr:    *addr,
 	}
+	var ln net.Listener
+	if len(activated) > 0 {
+		ln = activated[0]
+	} else if ln, err = listener.Listen(*addr, fs.FileMode(mode)); err != nil {
+		log.Fatal(err)
+	}
+
+	// flags take precedence over [tls] config
+	tlsConf := core.Conf().TLS
+	for _, f := range []struct{ flag, conf *string }{
+		{tlsCert, &tlsConf.Cert},
+		{tlsKey, &tlsConf.Key},
+		{tlsMinVersion, &tlsConf.MinVersion},
+		{tlsClientCA, &tlsConf.ClientCA},
+	} {
+		if *f.flag != "" {
+			*f.conf = *f.flag
+		}
+	}
+	if err := tlsConf.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	stopCertWatch := make(chan struct{})
+	var certReloader *listener.CertReloader
+	if tlsConf.Enabled() {
+		certReloader, err = listener.NewCertReloader(tlsConf.Cert, tlsConf.Key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.TLSConfig, err = listener.TLSConfig(tlsConf, certReloader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go certReloader.Watch(l, stopCertWatch)
+	}
+
+	// SIGHUP reloads config, and the certificate for renewals that keep modification times
+	go func() {
+		hupc := make(chan os.Signal, 1)
+		signal.Notify(hupc, syscall.SIGHUP)
+		for range hupc {
+			if res, err := core.Reload(); err != nil {
+				l.Printf("config reload failed, keeping previous config: %v\n", err)
+			} else {
+				l.Printf("config reloaded, added projects %v, removed projects %v, resized queues %v\n", res.Added, res.Removed, res.Resized)
+			}
+			if certReloader == nil {
+				continue
+			}
+			if err := certReloader.Load(); err != nil {
+				l.Printf("tls certificate reload failed: %v\n", err)
+				continue
+			}
+			l.Printf("tls certificate reloaded from %s\n", tlsConf.Cert)
+		}
+	}()
 	fmt.Print(INIT)
-	log.Printf("listening on %s", srv.Addr)
+	if srv.TLSConfig != nil {
+		log.Printf("listening on %s (https)", ln.Addr())
+	} else {
+		log.Printf("listening on %s", ln.Addr())
+	}
+	if metricsSrv != nil {
+		log.Printf("serving metrics on %s", metricsLn.Addr())
+		go func() {
+			if err := metricsSrv.Serve(metricsLn); err != http.ErrServerClosed {
+				l.Fatalf("metrics server listen and serve error %v\n", err)
+			}
+		}()
+	}
 	// log.Fatal(srv.ListenAndServe())
 
 	// queue drain drains all jobs in queue, but lets the job that is currently underway process without interruption
@@ -69,15 +209,27 @@ func main() {
 		signal.Notify(sigc, os.Interrupt)
 		<-sigc
 		fmt.Printf("\ngracefully shutting down\n")
-		core.Queues.DrainAll()
+		core.StopSchedules()
+		core.DrainAll()
+		close(stopCertWatch)
 
 		if err := srv.Shutdown(context.Background()); err != nil {
 			l.Printf("HTTP server shurdown error: %v\n", err)
 		}
+		if metricsSrv != nil {
+			if err := metricsSrv.Shutdown(context.Background()); err != nil {
+				l.Printf("metrics server shutdown error: %v\n", err)
+			}
+		}
 		httpServerCloseChan <- struct{}{}
 	}()
 
-	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+	if srv.TLSConfig != nil {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+	if err != http.ErrServerClosed {
 		l.Fatalf("http server listen and serve error %v\n", err)
 	}
 
@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minimal prometheus text format (version 0.0.4) exposition, only what ghhooks needs:
+// counters, gauges, gauges computed at scrape time and histograms, all with labels
+
+// ============ types ==================
+
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type series struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+type CounterVec struct {
+	series
+	mu     sync.Mutex
+	values map[string]*Sample
+}
+
+type GaugeVec struct {
+	CounterVec
+}
+
+type GaugeFunc struct {
+	series
+	collect func() []Sample
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+type HistogramVec struct {
+	series
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// ============ types ==================
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]collector)
+)
+
+// buckets (in seconds) used for build and step durations
+var DurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+func register(name string, c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("metrics: " + name + " is already registered")
+	}
+	registry[name] = c
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		series: series{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]*Sample),
+	}
+	register(name, c)
+	return c
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		CounterVec: CounterVec{
+			series: series{name: name, help: help, kind: "gauge", labels: labels},
+			values: make(map[string]*Sample),
+		},
+	}
+	register(name, g)
+	return g
+}
+
+// NewGaugeFunc - gauge whose samples are collected by calling collect on every scrape
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{
+		series:  series{name: name, help: help, kind: "gauge", labels: labels},
+		collect: collect,
+	}
+	register(name, g)
+	return g
+}
+
+// NewHistogramVec - buckets are upper bounds in increasing order, the +Inf bucket is always added
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	finite := make([]float64, 0, len(buckets))
+	for _, upper := range buckets {
+		if !math.IsInf(upper, 1) {
+			finite = append(finite, upper)
+		}
+	}
+	h := &HistogramVec{
+		series:  series{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: finite,
+		values:  make(map[string]*histogramValue),
+	}
+	register(name, h)
+	return h
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	sample, ok := c.values[key]
+	if !ok {
+		sample = &Sample{LabelValues: labelValues}
+		c.values[key] = sample
+	}
+	sample.Value += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[strings.Join(labelValues, "\xff")] = &Sample{LabelValues: labelValues, Value: v}
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = value
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.sum += v
+	value.count++
+}
+
+func (s series) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", s.name, strings.ReplaceAll(s.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.kind)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, s := range c.values {
+		samples = append(samples, *s)
+	}
+	c.mu.Unlock()
+	writeSamples(w, c.series, samples)
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeSamples(w, g.series, g.collect())
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := h.values[k]
+		names := append(append([]string(nil), h.labels...), "le")
+		for i, upper := range h.buckets {
+			labels := formatLabels(names, append(append([]string(nil), value.labelValues...), formatFloat(upper)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, value.counts[i])
+		}
+		labels := formatLabels(names, append(append([]string(nil), value.labelValues...), "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, value.labelValues), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, value.labelValues), value.count)
+	}
+}
+
+func writeSamples(w io.Writer, s series, samples []Sample) {
+	s.header(w)
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", s.name, formatLabels(s.labels, sample.LabelValues), formatFloat(sample.Value))
+	}
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler - serves every registered metric in prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		names := make([]string, 0, len(registry))
+		for name := range registry {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		collectors := make([]collector, 0, len(names))
+		for _, name := range names {
+			collectors = append(collectors, registry[name])
+		}
+		registryMu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
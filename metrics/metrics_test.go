@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerExposition(t *testing.T) {
+	counter := NewCounterVec("test_deliveries_total", "Deliveries\nby verdict.", "event", "verdict")
+	counter.Inc("push", "queued")
+	counter.Add(2, "push", "queued")
+	counter.Inc(`we"ird\`, "line\nbreak")
+
+	gauge := NewGaugeVec("test_subscribers", "Open connections.", "project")
+	gauge.Inc("a")
+	gauge.Inc("a")
+	gauge.Dec("a")
+	gauge.Set(-3, "b")
+
+	NewGaugeFunc("test_queue_depth", "Builds waiting.", []string{"project"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"a"}, Value: 4}}
+	})
+
+	histogram := NewHistogramVec("test_duration_seconds", "Durations.", []float64{1, 5, math.Inf(1)}, "project")
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		histogram.Observe(v, "a")
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type %s is not prometheus text format", ct)
+	}
+
+	// families sorted by name, label values escaped, help on one line, histogram buckets cumulative
+	// with +Inf written once
+	want := `# HELP test_deliveries_total Deliveries by verdict.
+# TYPE test_deliveries_total counter
+test_deliveries_total{event="push",verdict="queued"} 3
+test_deliveries_total{event="we\"ird\\",verdict="line\nbreak"} 1
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{project="a",le="1"} 2
+test_duration_seconds_bucket{project="a",le="5"} 3
+test_duration_seconds_bucket{project="a",le="+Inf"} 4
+test_duration_seconds_sum{project="a"} 14.5
+test_duration_seconds_count{project="a"} 4
+# HELP test_queue_depth Builds waiting.
+# TYPE test_queue_depth gauge
+test_queue_depth{project="a"} 4
+# HELP test_subscribers Open connections.
+# TYPE test_subscribers gauge
+test_subscribers{project="a"} 1
+test_subscribers{project="b"} -3
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{1, "1"},
+		{0.25, "0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.v); got != tt.want {
+			t.Errorf("formatFloat(%v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}